@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Sourav01112/server/internal/models"
+)
+
+// ------- uploads terminal logs to POST /api/terminal-logs with an API key that has the terminal-logs:write scope,
+// meant to run next to the terminals (cron, scheduled task). "-" reads the log from stdin
+//
+//	go run ./cmd/attlog -url https://attendance.example.com -terminal gate-1 -timezone Asia/Kolkata 1_attlog.dat
+func main() {
+	baseURL := flag.String("url", envOr("ATTENDANCE_URL", "http://localhost:8010"), "server address, or ATTENDANCE_URL")
+	apiKey := flag.String("key", os.Getenv("ATTENDANCE_API_KEY"), "API key, or ATTENDANCE_API_KEY")
+	terminal := flag.String("terminal", "", "terminal name, kept on the attendance records")
+	timezone := flag.String("timezone", "", "IANA timezone of the terminal clock, the server's when empty")
+	directionColumn := flag.Int("direction-column", 4, "1-based column with the in/out status, 0 when there is none")
+	codeField := flag.String("code-field", "employee_code", "employee_code or attr.<key>")
+	dryRun := flag.Bool("dry-run", false, "only report what would change")
+	asJSON := flag.Bool("json", false, "print the full report as JSON")
+	flag.Parse()
+
+	if *apiKey == "" || *terminal == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: attlog -key <api key> -terminal <name> [flags] <log file>... (- for stdin)")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	query := url.Values{
+		"terminal":         {*terminal},
+		"direction_column": {strconv.Itoa(*directionColumn)},
+		"code_field":       {*codeField},
+		"dry_run":          {strconv.FormatBool(*dryRun)},
+	}
+	if *timezone != "" {
+		query.Set("timezone", *timezone)
+	}
+	endpoint := *baseURL + "/api/terminal-logs?" + query.Encode()
+
+	failed := false
+	for _, path := range flag.Args() {
+		report, err := upload(endpoint, *apiKey, path)
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			failed = true
+			continue
+		}
+
+		if *asJSON {
+			out, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(out))
+			continue
+		}
+		printReport(path, report)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func upload(endpoint string, apiKey string, path string) (models.TerminalIngestReport, error) {
+	var report models.TerminalIngestReport
+
+	var file io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return report, err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return report, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return report, err
+	}
+	if err := form.Close(); err != nil {
+		return report, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, &body)
+	if err != nil {
+		return report, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-API-Key", apiKey)
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool                        `json:"success"`
+		Error   string                      `json:"error"`
+		Data    models.TerminalIngestReport `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return report, fmt.Errorf("unexpected response (%s): %w", resp.Status, err)
+	}
+	if !result.Success {
+		return report, fmt.Errorf("%s: %s", resp.Status, result.Error)
+	}
+
+	return result.Data, nil
+}
+
+func printReport(path string, report models.TerminalIngestReport) {
+	mode := ""
+	if report.DryRun {
+		mode = " (dry run)"
+	}
+	fmt.Printf("%s%s: %d punches, %d created, %d updated, %d unchanged, %d conflicts, %d skipped days, %d unmatched codes, %d invalid lines\n",
+		path, mode, report.Punches, report.Created, report.Updated, report.Unchanged,
+		len(report.Conflicts), len(report.Skipped), len(report.Unmatched), len(report.Invalid))
+
+	for _, u := range report.Unmatched {
+		if u.Reason != "" {
+			fmt.Printf("  unmatched code %s (%d punches): %s\n", u.Code, u.Punches, u.Reason)
+			continue
+		}
+		fmt.Printf("  unmatched code %s (%d punches)\n", u.Code, u.Punches)
+	}
+	for _, c := range report.Conflicts {
+		fmt.Printf("  conflict %s %s %s: record has %s, terminal has %s\n",
+			c.Code, c.Date, c.Field, c.Existing.Format(time.TimeOnly), c.Terminal.Format(time.TimeOnly))
+	}
+	for _, s := range report.Skipped {
+		fmt.Printf("  skipped %s %s: %s\n", s.Code, s.Date, s.Reason)
+	}
+	for _, l := range report.Invalid {
+		fmt.Printf("  line %d: %s (%q)\n", l.Line, l.Error, l.Text)
+	}
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Sourav01112/server/internal/tokens"
+)
+
+// ------- writes a new JWT signing key into the keys dir. rotation: add a key, restart with JWT_ACTIVE_KID set to it,
+// and once tokens signed by the old key have expired run with -retire <old kid> to keep only its public half
+func main() {
+	dir := flag.String("dir", "keys", "keys directory, same as JWT_KEYS_DIR")
+	alg := flag.String("alg", "EdDSA", "EdDSA or RS256")
+	kid := flag.String("kid", "", "key id, derived from the public key when empty")
+	retire := flag.String("retire", "", "kid whose private key should be replaced by its public key")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatal(err)
+	}
+
+	if *retire != "" {
+		if err := retireKey(*dir, *retire); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s is now verify only\n", *retire)
+		return
+	}
+
+	var private crypto.Signer
+	var err error
+	switch *alg {
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		log.Fatalf("unsupported -alg %s", *alg)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *kid == "" {
+		if *kid, err = tokens.KeyID(private.Public()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("wrote %s, sign with it by setting JWT_ACTIVE_KID=%s\n", path, *kid)
+}
+
+func retireKey(dir string, kid string) error {
+	path := filepath.Join(dir, kid+".pem")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return fmt.Errorf("%s is not a PEM file", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("%s is not a signing key", path)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pub.pem"), public, 0o644); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
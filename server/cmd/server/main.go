@@ -3,11 +3,14 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
 
 	"github.com/Sourav01112/server/internal/config"
 	"github.com/Sourav01112/server/internal/handlers"
 	"github.com/Sourav01112/server/internal/middleware"
 	"github.com/Sourav01112/server/internal/services"
+	"github.com/Sourav01112/server/internal/storage"
+	"github.com/Sourav01112/server/internal/tokens"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -20,16 +23,30 @@ func main() {
 		log.Println(".env not present")
 	}
 
+	tokens.InitKeys()
 	config.InitDatabase()
+	storage.InitStorage()
 
 	services.StartScheduler()
 
 	r := gin.Default()
 
+	// ------- X-Forwarded-For only counts from these (comma separated IPs/CIDRs), network policies rely on it
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Kiosk-Token, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Idempotent-Replayed")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -38,27 +55,121 @@ func main() {
 	})
 
 	// Public --------------
+	r.GET("/.well-known/jwks.json", handlers.Get_jwks)
+
 	auth := r.Group("/api/auth")
 	{
 		// managing here both logins of admin and employee
 		auth.POST("/login", handlers.Login)
+		auth.POST("/accept-invite", handlers.Accept_invite)
+		auth.POST("/2fa/setup", handlers.Setup_two_factor_login)
+		auth.POST("/2fa/verify", handlers.Verify_two_factor_login)
+		auth.GET("/oidc/login", handlers.Oidc_login)
+		auth.GET("/oidc/callback", handlers.Oidc_callback)
+	}
+
+	// Kiosk tablets, authenticated with their own token --------------------
+	kiosk := r.Group("/api/kiosk")
+	kiosk.Use(middleware.KioskMiddleware())
+	{
+		kiosk.GET("/qr", handlers.Get_kiosk_qr)
+		kiosk.POST("/punch", middleware.Idempotency(), handlers.Kiosk_pin_punch)
 	}
 
 	// Protected --------------------
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware())
 	{
-		api.POST("/checkin", handlers.Check_In)
-		api.POST("/checkout", handlers.Check_out)
+		api.POST("/checkin", middleware.Idempotency(), handlers.Check_In)
+		api.POST("/checkout", middleware.Idempotency(), handlers.Check_out)
 		api.GET("/attendance", handlers.Get_individual_attendance)
 		api.GET("/my-corrections", handlers.Get_individual_corrections)
 		api.POST("/correction", handlers.Request_correction)
+		api.GET("/correction/:id", handlers.Get_correction)
+		api.POST("/correction/:id/comments", handlers.Add_correction_comment)
+		api.POST("/correction/:id/attachments", handlers.Upload_correction_attachment)
+		api.GET("/correction/:id/attachments/:attachmentId", handlers.Download_correction_attachment)
+		api.GET("/notifications", handlers.Get_notifications)
+		api.POST("/2fa/enroll", handlers.Enroll_two_factor)
+		api.POST("/2fa/confirm", handlers.Confirm_two_factor)
+		api.POST("/2fa/disable", handlers.Disable_two_factor)
+		api.POST("/2fa/recovery-codes", handlers.Regenerate_recovery_codes)
+		api.GET("/sessions", handlers.Get_my_sessions)
+		api.DELETE("/sessions", handlers.Revoke_my_other_sessions)
+		api.DELETE("/sessions/:id", handlers.Revoke_my_session)
+		api.GET("/devices", handlers.Get_my_devices)
+		api.POST("/devices", handlers.Register_device)
+		api.POST("/kiosk-scan", middleware.Idempotency(), handlers.Kiosk_scan)
+		api.POST("/punches/sync", middleware.Idempotency(), handlers.Sync_punches)
+		api.PUT("/kiosk-pin", handlers.Set_kiosk_pin)
 
 		api.POST("/register-employee", handlers.Register_employee)
+		api.GET("/users", handlers.List_users)
+		api.POST("/users", handlers.Register_employee)
+		api.POST("/users/import", handlers.Import_users)
+		api.GET("/users/:id", handlers.Get_user)
+		api.PUT("/users/:id", handlers.Update_user)
+		api.DELETE("/users/:id", handlers.Delete_user)
+		api.POST("/users/:id/deactivate", handlers.Deactivate_user)
+		api.POST("/users/:id/reactivate", handlers.Reactivate_user)
+		api.POST("/users/:id/reset-password", handlers.Reset_user_password)
+		api.POST("/users/:id/invite/resend", handlers.Resend_invite)
+		api.POST("/users/:id/invite/revoke", handlers.Revoke_invite)
+		api.PUT("/users/:id/two-factor", handlers.Set_two_factor_requirement)
+		api.POST("/users/:id/two-factor/reset", handlers.Reset_two_factor)
+		api.GET("/users/:id/sessions", handlers.Get_user_sessions)
+		api.DELETE("/users/:id/sessions", handlers.Revoke_user_sessions)
+		api.DELETE("/users/:id/sessions/:session_id", handlers.Revoke_user_session)
+		api.GET("/users/:id/devices", handlers.Get_user_devices)
+		api.DELETE("/users/:id/devices", handlers.Reset_user_devices)
+		api.POST("/users/:id/devices/:device_id/approve", handlers.Approve_user_device)
+		api.DELETE("/users/:id/devices/:device_id", handlers.Remove_user_device)
+		api.DELETE("/users/:id/kiosk-pin", handlers.Reset_kiosk_pin)
+
+		api.GET("/invitations", handlers.List_invitations)
+		api.GET("/user-attributes", handlers.Get_user_attributes)
+		api.POST("/user-attributes", handlers.Create_user_attribute)
+		api.PUT("/user-attributes/:id", handlers.Update_user_attribute)
+		api.DELETE("/user-attributes/:id", handlers.Delete_user_attribute)
 		api.GET("/team-attendance", handlers.Get_team_attendance)
+		api.GET("/team-attendance/export", handlers.Export_team_attendance)
+		api.GET("/team-attendance/summary", handlers.Get_team_attendance_summary)
+		api.POST("/terminal-logs", handlers.Ingest_terminal_log)
+		api.GET("/attendance-reviews", handlers.Get_attendance_reviews)
+		api.POST("/attendance-reviews/:id", handlers.Review_attendance_flags)
 		api.GET("/pending-corrections", handlers.Get_pending_corrections)
 		api.PUT("/correction/:id/approve", handlers.Approve_correction)
 		api.PUT("/correction/:id/reject", handlers.Reject_correction)
+		api.PUT("/correction/:id/request-info", handlers.Request_correction_info)
+		api.POST("/corrections/bulk-review", handlers.Bulk_review_corrections)
+
+		api.GET("/approval-chains", handlers.Get_approval_chains)
+		api.POST("/approval-chains", handlers.Create_approval_chain)
+		api.PUT("/approval-chains/:id", handlers.Update_approval_chain)
+		api.DELETE("/approval-chains/:id", handlers.Delete_approval_chain)
+		api.GET("/sla-breaches", handlers.Get_sla_breaches)
+
+		api.GET("/directories", handlers.Get_directories)
+		api.POST("/directories", handlers.Create_directory)
+		api.PUT("/directories/:id", handlers.Update_directory)
+		api.DELETE("/directories/:id", handlers.Delete_directory)
+		api.POST("/directories/:id/sync", handlers.Sync_directory)
+		api.GET("/directory-sync-reports", handlers.Get_directory_sync_reports)
+
+		api.GET("/sites", handlers.Get_sites)
+		api.POST("/sites", handlers.Create_site)
+		api.PUT("/sites/:id", handlers.Update_site)
+		api.DELETE("/sites/:id", handlers.Delete_site)
+
+		api.GET("/kiosks", handlers.Get_kiosks)
+		api.POST("/kiosks", handlers.Create_kiosk)
+		api.PUT("/kiosks/:id", handlers.Update_kiosk)
+		api.DELETE("/kiosks/:id", handlers.Delete_kiosk)
+		api.POST("/kiosks/:id/token", handlers.Rotate_kiosk_token)
+
+		api.GET("/api-keys", handlers.Get_api_keys)
+		api.POST("/api-keys", handlers.Create_api_key)
+		api.DELETE("/api-keys/:id", handlers.Revoke_api_key)
 	}
 
 	port := os.Getenv("PORT")
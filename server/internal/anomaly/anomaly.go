@@ -0,0 +1,103 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+)
+
+const earthRadiusKm = 6371.0
+
+// ------- an earlier check-in or check-out of the same user
+type Punch struct {
+	At       time.Time
+	Location models.Location
+}
+
+// ------- limits come from the environment on every call so they can be tuned without a release
+type Limits struct {
+	MaxClockSkew  time.Duration // ANOMALY_MAX_CLOCK_SKEW, default 5m
+	MaxSpeedKmh   float64       // ANOMALY_MAX_SPEED_KMH, default 900 (an airliner)
+	RepeatedAfter int           // ANOMALY_REPEATED_AFTER, earlier punches at exactly the same spot, default 3
+}
+
+func LimitsFromEnv() Limits {
+	return Limits{
+		MaxClockSkew:  config.DurationFromEnv("ANOMALY_MAX_CLOCK_SKEW", 5*time.Minute),
+		MaxSpeedKmh:   float64(config.IntFromEnv("ANOMALY_MAX_SPEED_KMH", 900)),
+		RepeatedAfter: config.IntFromEnv("ANOMALY_REPEATED_AFTER", 3),
+	}
+}
+
+// ------- flags for a punch at serverTime. history is the user's earlier punches, newest first
+func Detect(punch string, loc models.Location, serverTime time.Time, history []Punch, limits Limits) []models.AttendanceFlag {
+	var flags []models.AttendanceFlag
+	flag := func(kind string, format string, args ...any) {
+		flags = append(flags, models.AttendanceFlag{
+			Type:      kind,
+			Punch:     punch,
+			Detail:    fmt.Sprintf(format, args...),
+			CreatedAt: serverTime,
+		})
+	}
+
+	if loc.Mock {
+		flag(models.FlagMockLocation, "device reported a mock location provider")
+	}
+
+	// real fixes always have some error, spoofing tools often report exactly 0
+	if loc.Accuracy != nil && *loc.Accuracy <= 0 {
+		flag(models.FlagZeroAccuracy, "accuracy reported as %.1f m", *loc.Accuracy)
+	}
+
+	if loc.Timestamp != nil {
+		skew := serverTime.Sub(*loc.Timestamp)
+		if skew.Abs() > limits.MaxClockSkew {
+			flag(models.FlagClockSkew, "device clock is %s off the server", skew.Round(time.Second).Abs())
+		}
+	}
+
+	if len(history) > 0 {
+		previous := history[0]
+		km := Distance(previous.Location, loc)
+
+		// ------- both fixes can be off by their accuracy, only the distance beyond that counts
+		for _, accuracy := range []*float64{previous.Location.Accuracy, loc.Accuracy} {
+			if accuracy != nil && *accuracy > 0 {
+				km -= *accuracy / 1000
+			}
+		}
+
+		hours := serverTime.Sub(previous.At).Hours()
+		if km > 1 && (hours <= 0 || km/hours > limits.MaxSpeedKmh) {
+			flag(models.FlagImpossibleTravel, "%.0f km from the previous punch %s earlier", km, serverTime.Sub(previous.At).Round(time.Minute))
+		}
+	}
+
+	// GPS jitter makes exact repeats very unlikely, a replayed or typed in position repeats perfectly
+	repeats := 0
+	for _, p := range history {
+		if p.Location.Latitude == loc.Latitude && p.Location.Longitude == loc.Longitude {
+			repeats++
+		}
+	}
+	if repeats >= limits.RepeatedAfter {
+		flag(models.FlagRepeatedCoordinates, "same coordinates as %d earlier punches", repeats)
+	}
+
+	return flags
+}
+
+// Distance is the great circle distance in km
+func Distance(a models.Location, b models.Location) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
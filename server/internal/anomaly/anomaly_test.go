@@ -0,0 +1,178 @@
+package anomaly
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Sourav01112/server/internal/models"
+)
+
+var (
+	mumbai    = models.Location{Latitude: 19.0760, Longitude: 72.8777}
+	delhi     = models.Location{Latitude: 28.7041, Longitude: 77.1025}
+	bangalore = models.Location{Latitude: 12.9716, Longitude: 77.5946}
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func at(loc models.Location, accuracy float64) models.Location {
+	loc.Accuracy = &accuracy
+	return loc
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b models.Location
+		want float64
+	}{
+		{"same point", mumbai, mumbai, 0},
+		{"one degree of longitude on the equator", models.Location{}, models.Location{Longitude: 1}, 111.19},
+		{"mumbai to delhi", mumbai, delhi, 1153.24},
+		{"symmetric", delhi, mumbai, 1153.24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(tt.a, tt.b); math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("Distance() = %.2f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	limits := Limits{MaxClockSkew: 5 * time.Minute, MaxSpeedKmh: 900, RepeatedAfter: 3}
+
+	// ------- 1.67 km east of bangalore
+	nearby := models.Location{Latitude: 12.9716, Longitude: 77.6100}
+
+	tests := []struct {
+		name    string
+		loc     models.Location
+		history []Punch
+		want    []string
+	}{
+		{
+			name: "clean first punch",
+			loc:  at(bangalore, 12),
+		},
+		{
+			name: "mock provider",
+			loc:  models.Location{Latitude: bangalore.Latitude, Longitude: bangalore.Longitude, Mock: true},
+			want: []string{models.FlagMockLocation},
+		},
+		{
+			name: "zero accuracy",
+			loc:  at(bangalore, 0),
+			want: []string{models.FlagZeroAccuracy},
+		},
+		{
+			name: "negative accuracy",
+			loc:  at(bangalore, -1),
+			want: []string{models.FlagZeroAccuracy},
+		},
+		{
+			name: "missing accuracy is not zero",
+			loc:  bangalore,
+		},
+		{
+			name: "device clock within the limit",
+			loc:  models.Location{Latitude: bangalore.Latitude, Longitude: bangalore.Longitude, Timestamp: ptr(now.Add(-4 * time.Minute))},
+		},
+		{
+			name: "device clock behind",
+			loc:  models.Location{Latitude: bangalore.Latitude, Longitude: bangalore.Longitude, Timestamp: ptr(now.Add(-10 * time.Minute))},
+			want: []string{models.FlagClockSkew},
+		},
+		{
+			name: "device clock ahead",
+			loc:  models.Location{Latitude: bangalore.Latitude, Longitude: bangalore.Longitude, Timestamp: ptr(now.Add(10 * time.Minute))},
+			want: []string{models.FlagClockSkew},
+		},
+		{
+			name:    "flying faster than an airliner",
+			loc:     delhi,
+			history: []Punch{{At: now.Add(-30 * time.Minute), Location: mumbai}},
+			want:    []string{models.FlagImpossibleTravel},
+		},
+		{
+			name:    "same trip in a plausible time",
+			loc:     delhi,
+			history: []Punch{{At: now.Add(-3 * time.Hour), Location: mumbai}},
+		},
+		{
+			name:    "only the newest punch is compared",
+			loc:     delhi,
+			history: []Punch{{At: now.Add(-3 * time.Hour), Location: mumbai}, {At: now.Add(-3*time.Hour - time.Minute), Location: delhi}},
+		},
+		{
+			name:    "jump inside the accuracy of both fixes",
+			loc:     at(nearby, 400),
+			history: []Punch{{At: now, Location: at(bangalore, 400)}},
+		},
+		{
+			name:    "jump beyond the accuracy of both fixes",
+			loc:     at(nearby, 100),
+			history: []Punch{{At: now, Location: at(bangalore, 100)}},
+			want:    []string{models.FlagImpossibleTravel},
+		},
+		{
+			name:    "previous punch at the same time",
+			loc:     nearby,
+			history: []Punch{{At: now, Location: bangalore}},
+			want:    []string{models.FlagImpossibleTravel},
+		},
+		{
+			name:    "previous punch after this one",
+			loc:     nearby,
+			history: []Punch{{At: now.Add(time.Minute), Location: bangalore}},
+			want:    []string{models.FlagImpossibleTravel},
+		},
+		{
+			name:    "under a kilometre never counts as travel",
+			loc:     models.Location{Latitude: 12.9716, Longitude: 77.6000},
+			history: []Punch{{At: now, Location: bangalore}},
+		},
+		{
+			name: "repeated coordinates below the threshold",
+			loc:  bangalore,
+			history: []Punch{
+				{At: now.Add(-24 * time.Hour), Location: bangalore},
+				{At: now.Add(-48 * time.Hour), Location: bangalore},
+			},
+		},
+		{
+			name: "repeated coordinates at the threshold",
+			loc:  bangalore,
+			history: []Punch{
+				{At: now.Add(-24 * time.Hour), Location: bangalore},
+				{At: now.Add(-48 * time.Hour), Location: bangalore},
+				{At: now.Add(-72 * time.Hour), Location: bangalore},
+			},
+			want: []string{models.FlagRepeatedCoordinates},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := Detect("check_in", tt.loc, now, tt.history, limits)
+
+			var got []string
+			for _, flag := range flags {
+				if flag.Punch != "check_in" || !flag.CreatedAt.Equal(now) || flag.Detail == "" {
+					t.Errorf("flag %+v is missing its punch, time or detail", flag)
+				}
+				got = append(got, flag.Type)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Detect() flags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
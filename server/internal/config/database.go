@@ -64,5 +64,23 @@ func InitDatabase() {
 		log.Printf("Already exists: %v", err)
 	}
 
+	if err := EnsureIndexes(); err != nil {
+		log.Fatalf("Failed to create indexes: %v", err)
+	}
+
 	log.Printf("[[[  Connected to MongoDB - Database  ]]]: %s", dbName)
 }
+
+// runs fn inside a MongoDB transaction, needs replica set (see docker-compose), retries on transient errors
+func WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := DB.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
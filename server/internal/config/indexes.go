@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdempotencyKeyTTL is how long a stored Idempotency-Key result is replayed before MongoDB drops it
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// ------- the unique ones are what keeps concurrent requests from writing twice, the handlers count on them
+var indexes = map[string][]mongo.IndexModel{
+	"users": {
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"attendance": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"synced_punches": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"idempotency_keys": {
+		{Keys: bson.D{{Key: "scope", Value: 1}, {Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(IdempotencyKeyTTL.Seconds()))},
+	},
+	"sessions": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	},
+	"devices": {
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "device_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"api_keys": {
+		{Keys: bson.D{{Key: "prefix", Value: 1}}},
+	},
+	"kiosks": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}},
+	},
+}
+
+// creates the indexes above, existing ones are left alone. a unique index fails on data that already
+// breaks it (e.g. two attendance records for one user and day, two users with one email). the server
+// doesn't start then, running without the guarantee would let the duplicates keep coming
+func EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := lowercaseEmails(ctx); err != nil {
+		return err
+	}
+	// ------- created without unique before, an index can't be changed in place
+	if err := dropNonUnique(ctx, "devices", "user_id_1_device_id_1"); err != nil {
+		return err
+	}
+
+	for collection, models := range indexes {
+		if _, err := DB.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("indexes on %s (merge duplicate records, then restart): %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// ------- emails used to be stored as typed while SSO and LDAP look them up lower case. fails like the
+// index does when two users differ only in case, one of them has to be merged or renamed first
+func lowercaseEmails(ctx context.Context) error {
+	_, err := DB.Collection("users").UpdateMany(ctx,
+		bson.M{"email": bson.M{"$regex": "[A-Z]"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"email": bson.M{"$toLower": "$email"}}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("lower casing user emails (merge users that differ only in case, then restart): %w", err)
+	}
+	return nil
+}
+
+func dropNonUnique(ctx context.Context, collection string, name string) error {
+	specs, err := DB.Collection(collection).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return fmt.Errorf("indexes on %s: %w", collection, err)
+	}
+	for _, spec := range specs {
+		if spec.Name == name && (spec.Unique == nil || !*spec.Unique) {
+			if _, err := DB.Collection(collection).Indexes().DropOne(ctx, name); err != nil {
+				return fmt.Errorf("dropping index %s on %s: %w", name, collection, err)
+			}
+		}
+	}
+	return nil
+}
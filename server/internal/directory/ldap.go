@@ -0,0 +1,168 @@
+package directory
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid directory credentials")
+	ErrNotInGroup         = errors.New("user is not in any mapped directory group")
+)
+
+const timeout = 10 * time.Second
+
+// ------- a directory user after the attribute mapping
+type Entry struct {
+	DN     string
+	ID     string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// ------- finds the user by email with the service account, then binds as them with their password
+func Authenticate(dir models.Directory, email string, password string) (Entry, error) {
+	// an empty password is an unauthenticated bind that most servers accept
+	if password == "" {
+		return Entry{}, ErrInvalidCredentials
+	}
+
+	conn, err := connect(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf("(&%s(%s=%s))", userFilter(dir), attribute(dir.EmailAttribute, "mail"), ldap.EscapeFilter(email))
+	entries, err := search(conn, dir, filter)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entries) != 1 {
+		return Entry{}, ErrInvalidCredentials
+	}
+
+	if err := conn.Bind(entries[0].DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return Entry{}, ErrInvalidCredentials
+		}
+		return Entry{}, err
+	}
+
+	return entries[0], nil
+}
+
+// ------- everyone in one of the mapped groups, what the sync job treats as the truth
+func Members(dir models.Directory) ([]Entry, error) {
+	if len(dir.GroupRoles) == 0 {
+		return nil, errors.New("directory has no group_roles to sync")
+	}
+
+	conn, err := connect(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var groups strings.Builder
+	for _, group := range dir.GroupRoles {
+		fmt.Fprintf(&groups, "(%s=%s)", attribute(dir.MemberOfAttribute, "memberOf"), ldap.EscapeFilter(group.GroupDN))
+	}
+
+	return search(conn, dir, fmt.Sprintf("(&%s(|%s))", userFilter(dir), groups.String()))
+}
+
+// ------- role of the first group_roles entry the user is in. without any group_roles everyone is an employee
+func RoleFor(dir models.Directory, entry Entry) (string, error) {
+	if len(dir.GroupRoles) == 0 {
+		return models.RoleEmployee, nil
+	}
+	for _, group := range dir.GroupRoles {
+		for _, member := range entry.Groups {
+			if strings.EqualFold(member, group.GroupDN) {
+				return group.Role, nil
+			}
+		}
+	}
+	return "", ErrNotInGroup
+}
+
+func connect(dir models.Directory) (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(dir.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", dir.URL, err)
+	}
+	conn.SetTimeout(timeout)
+
+	if dir.StartTLS {
+		host := strings.TrimPrefix(strings.TrimPrefix(dir.URL, "ldap://"), "ldaps://")
+		host, _, _ = strings.Cut(host, ":")
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("start tls: %w", err)
+		}
+	}
+
+	if dir.BindDN != "" {
+		if err := conn.Bind(dir.BindDN, os.Getenv(dir.BindPasswordEnv)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("service bind: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func search(conn *ldap.Conn, dir models.Directory, filter string) ([]Entry, error) {
+	idAttr := attribute(dir.IDAttribute, "uid")
+	emailAttr := attribute(dir.EmailAttribute, "mail")
+	nameAttr := attribute(dir.NameAttribute, "cn")
+	memberOfAttr := attribute(dir.MemberOfAttribute, "memberOf")
+
+	request := ldap.NewSearchRequest(
+		dir.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(timeout.Seconds()), false,
+		filter, []string{idAttr, emailAttr, nameAttr, memberOfAttr}, nil,
+	)
+
+	// paged so large directories don't hit the server's size limit
+	result, err := conn.SearchWithPaging(request, 500)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		entries = append(entries, Entry{
+			DN:     e.DN,
+			ID:     e.GetAttributeValue(idAttr),
+			Email:  strings.ToLower(e.GetAttributeValue(emailAttr)),
+			Name:   e.GetAttributeValue(nameAttr),
+			Groups: e.GetAttributeValues(memberOfAttr),
+		})
+	}
+	return entries, nil
+}
+
+func userFilter(dir models.Directory) string {
+	if dir.UserFilter != "" {
+		return dir.UserFilter
+	}
+	return "(objectClass=person)"
+}
+
+func attribute(configured string, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
@@ -2,33 +2,44 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/Sourav01112/server/internal/config"
 	"github.com/Sourav01112/server/internal/models"
 	"github.com/Sourav01112/server/internal/utils"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func Register_employee(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
-	if user.Role != "admin" {
+	if !canGrantAnyRole(user) {
 		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
 		return
 	}
 
 	var req struct {
-		Email    string `json:"email" binding:"required"`
-		Password string `json:"password" binding:"required"`
-		Name     string `json:"name" binding:"required"`
-		Role     string `json:"role" binding:"required"`
+		Email     string `json:"email" binding:"required"`
+		Name      string `json:"name" binding:"required"`
+		Role      string `json:"role" binding:"required"`
+		ManagerID string `json:"manager_id"`
+		Group     string `json:"group"`
+		Site      string `json:"site"`
+		Shift     string `json:"shift"`
+		Timezone  string `json:"timezone"`
+
+		models.EmployeeProfile
+
+		Attributes map[string]any `json:"attributes"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -36,35 +47,119 @@ func Register_employee(c *gin.Context) {
 		return
 	}
 
-	var existingUser models.User
-	err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"email": req.Email}).Decode(&existingUser)
+	req.Email = normalizeEmail(req.Email)
 
-	if err == nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "User already exists")
+	var fieldErrors []utils.FieldError
+	fieldErrors = append(fieldErrors, validateEmail(req.Email)...)
+	fieldErrors = append(fieldErrors, validateRole(user, req.Role)...)
+	fieldErrors = append(fieldErrors, validateShift(req.Shift)...)
+	fieldErrors = append(fieldErrors, validateTimezone(req.Timezone)...)
+	fieldErrors = append(fieldErrors, validateProfile(req.EmployeeProfile)...)
+
+	taken, err := employeeCodeTaken(context.TODO(), req.EmployeeCode, primitive.NilObjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check employee code")
 		return
 	}
+	if taken {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "employee_code", Message: "is already used by another user"})
+	}
+
+	taken, err = emailTaken(context.TODO(), req.Email, primitive.NilObjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check email")
+		return
+	}
+	if taken {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "email", Message: "is already used by another user"})
+	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	definitions, err := loadAttributeDefinitions(context.TODO())
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash password")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+		return
+	}
+	attributes, attributeErrors := applyAttributes(definitions, nil, req.Attributes)
+	fieldErrors = append(fieldErrors, attributeErrors...)
+
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid user details", fieldErrors)
+		return
+	}
+
+	var managerID *primitive.ObjectID
+	if req.ManagerID != "" {
+		id, err := primitive.ObjectIDFromHex(req.ManagerID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid manager ID")
+			return
+		}
+		managerID = &id
+	}
+
+	var existingUser models.User
+	err = config.DB.Collection("users").FindOne(context.TODO(), bson.M{"email": req.Email}).Decode(&existingUser)
+
+	if err == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "User already exists")
 		return
 	}
 
+	// ------- no password yet, the employee sets one through the invitation link
 	newUser := models.User{
+		ID:        primitive.NewObjectID(),
 		Email:     req.Email,
-		Password:  string(hashedPassword),
 		Name:      req.Name,
 		Role:      req.Role,
+		ManagerID: managerID,
+		Group:     req.Group,
+		Site:      req.Site,
+		Shift:     req.Shift,
+		Timezone:  req.Timezone,
+		Status:    "invited",
 		CreatedAt: time.Now(),
+
+		EmployeeProfile: req.EmployeeProfile,
+
+		Attributes: attributes,
 	}
 
-	_, err = config.DB.Collection("users").InsertOne(context.TODO(), newUser)
+	var token string
+	err = config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		if _, err := config.DB.Collection("users").InsertOne(sc, newUser); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = createInvitation(sc, newUser, user)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(sc, user, "user.invited", "user", newUser.ID, map[string]any{"role": newUser.Role})
+	})
+
+	if mongo.IsDuplicateKeyError(err) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "User already exists")
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	utils.SuccessResponse(c, gin.H{"message": "Employee registered successfully"})
+	if err := sendInvitation(newUser, token); err != nil {
+		utils.SuccessResponse(c, gin.H{
+			"message": "Employee registered, but the invitation email could not be sent. Resend it from the user list",
+			"user_id": newUser.ID.Hex(),
+		})
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message": "Employee registered, invitation sent",
+		"user_id": newUser.ID.Hex(),
+	})
 }
 
 func Get_team_attendance(c *gin.Context) {
@@ -75,8 +170,13 @@ func Get_team_attendance(c *gin.Context) {
 		return
 	}
 
+	filter, ok := teamAttendanceFilter(c)
+	if !ok {
+		return
+	}
+
 	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
-	cursor, err := config.DB.Collection("attendance").Find(context.TODO(), bson.M{}, opts)
+	cursor, err := config.DB.Collection("attendance").Find(context.TODO(), filter, opts)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attendance")
 		return
@@ -95,12 +195,16 @@ func Get_team_attendance(c *gin.Context) {
 func Get_pending_corrections(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
+	// ------- admins oversee every pending correction, other reviewers only see steps waiting on them
+	filter := bson.M{"status": "pending"}
 	if user.Role != "admin" {
-		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
-		return
+		filter["$or"] = bson.A{
+			bson.M{"assigned_to": user.ID},
+			bson.M{"assigned_to": nil, "assigned_role": user.Role},
+		}
 	}
 
-	cursor, err := config.DB.Collection("corrections").Find(context.TODO(), bson.M{"status": "pending"})
+	cursor, err := config.DB.Collection("corrections").Find(context.TODO(), filter)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch corrections")
 		return
@@ -119,72 +223,32 @@ func Get_pending_corrections(c *gin.Context) {
 func Approve_correction(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
-	if user.Role != "admin" {
-		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
-		return
-	}
-
 	correctionID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid correction ID")
 		return
 	}
 
-	var correction models.Correction
-	err = config.DB.Collection("corrections").FindOne(context.TODO(), bson.M{"_id": correctionID}).Decode(&correction)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "Correction not found")
-		return
+	// ------- comments are optional on approval, so an empty body is fine
+	var reqBody struct {
+		Comments string `json:"comments"`
 	}
 
-	if correction.Status != "pending" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Correction already processed")
-		return
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&reqBody); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+			return
+		}
 	}
 
-	now := time.Now()
-
-	_, err = config.DB.Collection("corrections").UpdateOne(
-		context.TODO(),
-		bson.M{"_id": correctionID},
-		bson.M{"$set": bson.M{
-			"status":      "approved",
-			"reviewed_at": &now,
-			"reviewed_by": &user.ID,
-		}},
-	)
-
+	status, err := reviewCorrection(context.TODO(), correctionID, user, "approved", reqBody.Comments, "single")
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to approve correction")
+		reviewErrorResponse(c, err, "Failed to approve correction")
 		return
 	}
 
-	updateFields := bson.M{
-		"updated_at": now,
-		"status":     "valid",
-	}
-
-	if correction.RequestedCheckIn != nil {
-		updateFields["check_in"] = correction.RequestedCheckIn
-	}
-	if correction.RequestedCheckOut != nil {
-		updateFields["check_out"] = correction.RequestedCheckOut
-	}
-
-	// will be checking here if both times are present, if yes, then add up and push to new attendance time, employee will see new added up date
-	if correction.RequestedCheckIn != nil && correction.RequestedCheckOut != nil {
-		totalHours := correction.RequestedCheckOut.Sub(*correction.RequestedCheckIn).Hours()
-		updateFields["total_hours"] = totalHours
-	}
-
-	_, err = config.DB.Collection("attendance").UpdateOne(
-		context.TODO(),
-		bson.M{"_id": correction.AttendanceID},
-		bson.M{"$set": updateFields},
-	)
-
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update attendance")
+	if status == "pending" {
+		utils.SuccessResponse(c, gin.H{"message": "Approval recorded, waiting for the next approver"})
 		return
 	}
 
@@ -194,11 +258,6 @@ func Approve_correction(c *gin.Context) {
 func Reject_correction(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
-	if user.Role != "admin" {
-		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
-		return
-	}
-
 	correctionID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid correction ID")
@@ -214,36 +273,280 @@ func Reject_correction(c *gin.Context) {
 		return
 	}
 
-	var correction models.Correction
-
-	err = config.DB.Collection("corrections").FindOne(context.TODO(), bson.M{"_id": correctionID}).Decode(&correction)
+	_, err = reviewCorrection(context.TODO(), correctionID, user, "rejected", reqBody.Comments, "single")
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "Correction not found")
+		reviewErrorResponse(c, err, "Failed to reject correction")
 		return
 	}
 
-	if correction.Status != "pending" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Correction already processed")
+	utils.SuccessResponse(c, gin.H{"message": "Correction rejected successfully"})
+}
+
+const maxBulkReview = 100
+
+// ------- each id is reviewed in its own transaction, so one conflict doesn't roll back the rest of the batch
+func Bulk_review_corrections(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.BulkReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
 		return
 	}
 
-	now := time.Now()
-
-	_, err = config.DB.Collection("corrections").UpdateOne(
-		context.TODO(),
-		bson.M{"_id": correctionID},
-		bson.M{"$set": bson.M{
-			"status":      "rejected",
-			"comments":    reqBody.Comments,
-			"reviewed_at": &now,
-			"reviewed_by": &user.ID,
-		}},
-	)
+	var decision string
+	switch req.Action {
+	case "approve":
+		decision = "approved"
+	case "reject":
+		decision = "rejected"
+		if req.Comments == "" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Comments are required for rejection")
+			return
+		}
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Action must be approve or reject")
+		return
+	}
 
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reject correction")
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkReview {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Between 1 and %d correction IDs are required", maxBulkReview))
 		return
 	}
 
-	utils.SuccessResponse(c, gin.H{"message": "Correction rejected successfully"})
+	seen := make(map[string]bool)
+	results := make([]models.BulkReviewResult, 0, len(req.IDs))
+
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		result := models.BulkReviewResult{ID: id}
+
+		correctionID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			result.Result = "invalid"
+			result.Error = "Invalid correction ID"
+			results = append(results, result)
+			continue
+		}
+
+		_, err = reviewCorrection(context.TODO(), correctionID, user, decision, req.Comments, "bulk")
+
+		var validationErr *correctionValidationError
+		switch {
+		case err == nil:
+			result.Result = decision
+		case errors.Is(err, errCorrectionConflict):
+			result.Result = "conflict"
+			result.Error = "Correction already processed by another reviewer"
+		case errors.Is(err, errCorrectionNotFound):
+			result.Result = "invalid"
+			result.Error = "Correction not found"
+		case errors.Is(err, errAttendanceNotFound):
+			result.Result = "invalid"
+			result.Error = "Attendance record not found"
+		case errors.Is(err, errNotStepReviewer):
+			result.Result = "invalid"
+			result.Error = "Not the reviewer for the current approval step"
+		case errors.As(err, &validationErr):
+			result.Result = "invalid"
+			result.Error = "Invalid correction times"
+			result.Errors = validationErr.Errors
+		default:
+			log.Printf("Bulk review of correction %s failed: %v", id, err)
+			result.Result = "failed"
+			result.Error = "Failed to review correction"
+		}
+
+		results = append(results, result)
+	}
+
+	utils.SuccessResponse(c, gin.H{"results": results})
+}
+
+var (
+	errCorrectionNotFound = errors.New("correction not found")
+	errCorrectionConflict = errors.New("correction already processed")
+	errAttendanceNotFound = errors.New("attendance not found")
+	errNotStepReviewer    = errors.New("not the reviewer for this step")
+)
+
+// ------- records one reviewer's decision on the current approval step, all in one transaction.
+// the status + current_step filter is the compare-and-set so two reviewers can't both win.
+// returns the correction status afterwards, "pending" means it moved on to the next step
+func reviewCorrection(ctx context.Context, correctionID primitive.ObjectID, reviewer models.User, decision string, comments string, source string) (string, error) {
+	var status string
+
+	err := config.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		now := time.Now()
+
+		var correction models.Correction
+		err := config.DB.Collection("corrections").FindOne(sc, bson.M{"_id": correctionID}).Decode(&correction)
+		if err == mongo.ErrNoDocuments {
+			return errCorrectionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// ------- a reviewer may still decide while waiting on the employee for more info
+		if correction.Status != "pending" && correction.Status != "needs_info" {
+			return errCorrectionConflict
+		}
+
+		filter := bson.M{"_id": correctionID, "status": correction.Status}
+
+		// ------- corrections from before approval chains have no steps, they get the old single admin review
+		steps := correction.Steps
+		if len(steps) == 0 {
+			steps = []models.ApprovalStep{legacyStep(correction)}
+		} else {
+			filter["current_step"] = correction.CurrentStep
+		}
+
+		current := correction.CurrentStep
+		if current >= len(steps) || reviewer.ID == correction.UserID {
+			return errNotStepReviewer
+		}
+
+		// ------- admins may decide any step, so a correction whose reviewer left or never answers
+		// doesn't get stuck. the audit entry says it was an override
+		override := !canReviewStep(reviewer, steps[current])
+		if override && reviewer.Role != "admin" {
+			return errNotStepReviewer
+		}
+
+		steps[current].Decision = decision
+		steps[current].DecidedBy = &reviewer.ID
+		steps[current].DecidedAt = &now
+		steps[current].Comment = comments
+
+		set := bson.M{"steps": steps}
+
+		final := decision == "rejected" || current == len(steps)-1
+		if final {
+			status = decision
+			set["status"] = decision
+			set["reviewed_at"] = &now
+			set["reviewed_by"] = &reviewer.ID
+			set["assigned_to"] = nil
+			set["assigned_role"] = ""
+			if comments != "" {
+				set["comments"] = comments
+			}
+		} else {
+			// ------- a needs_info correction approved at this step waits on the next reviewer again
+			status = "pending"
+			set["status"] = "pending"
+			steps[current+1].Decision = "pending"
+			set["current_step"] = current + 1
+			set["step_started_at"] = &now
+			set["reminded_at"] = nil
+			set["escalated_at"] = nil
+			for key, value := range stepAssignment(steps[current+1]) {
+				set[key] = value
+			}
+		}
+
+		result, err := config.DB.Collection("corrections").UpdateOne(sc, filter, bson.M{"$set": set})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errCorrectionConflict
+		}
+
+		details := map[string]any{"source": source, "step": current, "step_name": steps[current].Name}
+		if override {
+			details["override"] = true
+		}
+		if comments != "" {
+			details["comments"] = comments
+		}
+		action := "correction." + decision
+		if !final {
+			action = "correction.step_approved"
+		}
+		if err := recordAudit(sc, reviewer, action, "correction", correctionID, details); err != nil {
+			return err
+		}
+
+		// ------- attendance only changes once the last step has approved
+		if !final || decision != "approved" {
+			return nil
+		}
+
+		var attendance models.Attendance
+		err = config.DB.Collection("attendance").FindOne(sc, bson.M{"_id": correction.AttendanceID}).Decode(&attendance)
+		if err == mongo.ErrNoDocuments {
+			return errAttendanceNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// ------- the record may have changed since the request was made, so check the times again
+		fieldErrors, err := validateCorrectionTimes(sc, attendance, correction.RequestedCheckIn, correction.RequestedCheckOut)
+		if err != nil {
+			return err
+		}
+		if len(fieldErrors) > 0 {
+			return &correctionValidationError{Errors: fieldErrors}
+		}
+
+		updateFields := bson.M{
+			"updated_at": now,
+			"status":     "valid",
+		}
+
+		if correction.RequestedCheckIn != nil {
+			updateFields["check_in"] = correction.RequestedCheckIn
+		}
+		if correction.RequestedCheckOut != nil {
+			updateFields["check_out"] = correction.RequestedCheckOut
+		}
+
+		// will be checking here if both times are present, if yes, then add up and push to new attendance time, employee will see new added up date
+		if correction.RequestedCheckIn != nil && correction.RequestedCheckOut != nil {
+			totalHours := correction.RequestedCheckOut.Sub(*correction.RequestedCheckIn).Hours()
+			updateFields["total_hours"] = totalHours
+		}
+
+		updated, err := config.DB.Collection("attendance").UpdateOne(
+			sc,
+			bson.M{"_id": correction.AttendanceID},
+			bson.M{"$set": updateFields},
+		)
+		if err != nil {
+			return err
+		}
+		if updated.MatchedCount == 0 {
+			return errAttendanceNotFound
+		}
+
+		return nil
+	})
+
+	return status, err
+}
+
+func reviewErrorResponse(c *gin.Context, err error, fallback string) {
+	var validationErr *correctionValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.ValidationErrorResponse(c, "Invalid correction times", validationErr.Errors)
+	case errors.Is(err, errCorrectionNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Correction not found")
+	case errors.Is(err, errCorrectionConflict):
+		utils.ErrorResponse(c, http.StatusConflict, "Correction already processed by another reviewer")
+	case errors.Is(err, errAttendanceNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Attendance record not found")
+	case errors.Is(err, errNotStepReviewer):
+		utils.ErrorResponse(c, http.StatusForbidden, "Not the reviewer for the current approval step")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, fallback)
+	}
 }
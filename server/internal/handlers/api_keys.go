@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/middleware"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Get_api_keys(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := config.DB.Collection("api_keys").Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	keys := []models.APIKey{}
+	if err = cursor.All(context.TODO(), &keys); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode API keys")
+		return
+	}
+
+	utils.SuccessResponse(c, keys)
+}
+
+// ------- the raw key is in this response only, it is stored as a sha256 hash
+func Create_api_key(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if fieldErrors := validateAPIKey(req); len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid API key", fieldErrors)
+		return
+	}
+
+	prefix := strings.ToLower(rand.Text()[:8])
+	rawKey := middleware.APIKeyPrefix + prefix + "_" + rand.Text()
+
+	key := models.APIKey{
+		Name:       req.Name,
+		Prefix:     prefix,
+		KeyHash:    hashToken(rawKey),
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+		CreatedBy:  user.ID,
+		CreatedAt:  time.Now(),
+	}
+
+	result, err := config.DB.Collection("api_keys").InsertOne(context.TODO(), key)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	key.ID = result.InsertedID.(primitive.ObjectID)
+
+	auditAPIKeyChange(user, "api_key.created", key.ID, map[string]any{"name": key.Name, "scopes": key.Scopes})
+
+	utils.SuccessResponse(c, gin.H{"api_key": key, "key": rawKey})
+}
+
+// ------- revoked keys stay listed so last use can still be looked up
+func Revoke_api_key(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	var key models.APIKey
+	err = config.DB.Collection("api_keys").FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"_id": keyID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&key)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "API key not found or already revoked")
+		return
+	}
+
+	auditAPIKeyChange(user, "api_key.revoked", key.ID, map[string]any{"name": key.Name})
+
+	utils.SuccessResponse(c, key)
+}
+
+func validateAPIKey(req models.APIKeyRequest) []utils.FieldError {
+	var errs []utils.FieldError
+
+	if len(req.Scopes) == 0 {
+		errs = append(errs, utils.FieldError{Field: "scopes", Message: "at least one scope is required"})
+	}
+	for i, scope := range req.Scopes {
+		if _, ok := models.APIKeyScopes[scope]; !ok {
+			errs = append(errs, utils.FieldError{Field: "scopes[" + strconv.Itoa(i) + "]", Message: "must be one of " + strings.Join(apiKeyScopeNames(), ", ")})
+		}
+	}
+
+	for i, cidr := range req.AllowedIPs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = append(errs, utils.FieldError{Field: "allowed_ips[" + strconv.Itoa(i) + "]", Message: "must be a CIDR like 10.0.0.0/24"})
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		errs = append(errs, utils.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+
+	return errs
+}
+
+func apiKeyScopeNames() []string {
+	names := make([]string, 0, len(models.APIKeyScopes))
+	for name := range models.APIKeyScopes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func auditAPIKeyChange(actor models.User, action string, keyID primitive.ObjectID, details map[string]any) {
+	if err := recordAudit(context.TODO(), actor, action, "api_key", keyID, details); err != nil {
+		logAuditFailure(action, keyID, err)
+	}
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Get_approval_chains(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}})
+	cursor, err := config.DB.Collection("approval_chains").Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch approval chains")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	chains := []models.ApprovalChain{}
+	if err = cursor.All(context.TODO(), &chains); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode approval chains")
+		return
+	}
+
+	utils.SuccessResponse(c, chains)
+}
+
+func Create_approval_chain(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req models.ApprovalChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors, err := validateChainSteps(context.TODO(), req.Steps)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check approval chain reviewers")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid approval chain", fieldErrors)
+		return
+	}
+
+	now := time.Now()
+	chain := models.ApprovalChain{
+		Name:      req.Name,
+		Priority:  req.Priority,
+		Rules:     req.Rules,
+		Steps:     req.Steps,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	result, err := config.DB.Collection("approval_chains").InsertOne(context.TODO(), chain)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create approval chain")
+		return
+	}
+	chain.ID = result.InsertedID.(primitive.ObjectID)
+
+	utils.SuccessResponse(c, chain)
+}
+
+func Update_approval_chain(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	chainID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid approval chain ID")
+		return
+	}
+
+	var req models.ApprovalChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors, err := validateChainSteps(context.TODO(), req.Steps)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check approval chain reviewers")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid approval chain", fieldErrors)
+		return
+	}
+
+	set := bson.M{
+		"name":       req.Name,
+		"priority":   req.Priority,
+		"rules":      req.Rules,
+		"steps":      req.Steps,
+		"updated_at": time.Now(),
+	}
+	if req.Active != nil {
+		set["active"] = *req.Active
+	}
+
+	// ------- corrections already in flight keep the steps they were created with
+	result, err := config.DB.Collection("approval_chains").UpdateOne(context.TODO(), bson.M{"_id": chainID}, bson.M{"$set": set})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update approval chain")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Approval chain not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Approval chain updated successfully"})
+}
+
+func Delete_approval_chain(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	chainID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid approval chain ID")
+		return
+	}
+
+	result, err := config.DB.Collection("approval_chains").DeleteOne(context.TODO(), bson.M{"_id": chainID})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete approval chain")
+		return
+	}
+	if result.DeletedCount == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Approval chain not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Approval chain deleted successfully"})
+}
+
+// ------- a step nobody can decide would hold every correction routed to the chain until an admin steps in
+func validateChainSteps(ctx context.Context, steps []models.ChainStep) ([]utils.FieldError, error) {
+	var errs []utils.FieldError
+
+	if len(steps) == 0 {
+		errs = append(errs, utils.FieldError{Field: "steps", Message: "at least one step is required"})
+	}
+
+	for i, step := range steps {
+		field := "steps[" + strconv.Itoa(i) + "]"
+		switch step.ReviewerType {
+		case "manager":
+		case "role":
+			if step.ReviewerRole == "" {
+				errs = append(errs, utils.FieldError{Field: field + ".reviewer_role", Message: "required for role steps"})
+			} else if !slices.Contains(models.Roles, step.ReviewerRole) {
+				errs = append(errs, utils.FieldError{Field: field + ".reviewer_role", Message: "must be one of " + strings.Join(models.Roles, ", ")})
+			}
+		case "user":
+			if step.ReviewerID == nil {
+				errs = append(errs, utils.FieldError{Field: field + ".reviewer_id", Message: "required for user steps"})
+				continue
+			}
+			count, err := config.DB.Collection("users").CountDocuments(ctx, bson.M{
+				"_id":    *step.ReviewerID,
+				"status": bson.M{"$nin": bson.A{"deactivated", "invited"}},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				errs = append(errs, utils.FieldError{Field: field + ".reviewer_id", Message: "must be an existing active user"})
+			}
+		default:
+			errs = append(errs, utils.FieldError{Field: field + ".reviewer_type", Message: "must be manager, role or user"})
+		}
+	}
+
+	return errs, nil
+}
+
+// picks the first active chain whose rules match, falling back to a single admin step like before chains existed
+func selectApprovalSteps(ctx context.Context, employee models.User, attendance models.Attendance, requestedIn, requestedOut *time.Time) (*primitive.ObjectID, []models.ApprovalStep, error) {
+	hoursChanged := correctionHoursChanged(attendance, requestedIn, requestedOut)
+
+	ageDays := 0
+	if day, err := time.ParseInLocation("2006-01-02", attendance.Date, time.Local); err == nil {
+		ageDays = int(time.Since(day).Hours() / 24)
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: 1}})
+	cursor, err := config.DB.Collection("approval_chains").Find(ctx, bson.M{"active": true}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var chains []models.ApprovalChain
+	if err = cursor.All(ctx, &chains); err != nil {
+		return nil, nil, err
+	}
+
+	for _, chain := range chains {
+		if !chainMatches(chain.Rules, employee, hoursChanged, ageDays) {
+			continue
+		}
+
+		steps := make([]models.ApprovalStep, 0, len(chain.Steps))
+		for _, step := range chain.Steps {
+			steps = append(steps, resolveStep(step, employee))
+		}
+		steps[0].Decision = "pending"
+
+		chainID := chain.ID
+		return &chainID, steps, nil
+	}
+
+	return nil, []models.ApprovalStep{{
+		Name:         "Admin review",
+		ReviewerType: "role",
+		ReviewerRole: "admin",
+		Decision:     "pending",
+	}}, nil
+}
+
+func chainMatches(rules models.ChainRules, employee models.User, hoursChanged float64, ageDays int) bool {
+	if rules.MinHoursChanged > 0 && hoursChanged <= rules.MinHoursChanged {
+		return false
+	}
+	if rules.MinAttendanceAgeDays > 0 && ageDays < rules.MinAttendanceAgeDays {
+		return false
+	}
+	if len(rules.Groups) > 0 {
+		for _, group := range rules.Groups {
+			if group == employee.Group {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func resolveStep(step models.ChainStep, employee models.User) models.ApprovalStep {
+	resolved := models.ApprovalStep{
+		Name:         step.Name,
+		ReviewerType: step.ReviewerType,
+		ReviewerRole: step.ReviewerRole,
+		ReviewerID:   step.ReviewerID,
+		Decision:     "waiting",
+	}
+
+	// ------- employees without a manager go to the admins instead of getting stuck
+	if step.ReviewerType == "manager" {
+		resolved.ReviewerID = employee.ManagerID
+		if employee.ManagerID == nil {
+			resolved.ReviewerRole = "admin"
+		}
+	}
+
+	return resolved
+}
+
+// how much the worked hours on the record would move if the correction went through
+func correctionHoursChanged(attendance models.Attendance, requestedIn, requestedOut *time.Time) float64 {
+	checkIn := attendance.CheckIn
+	if requestedIn != nil {
+		checkIn = requestedIn
+	}
+	checkOut := attendance.CheckOut
+	if requestedOut != nil {
+		checkOut = requestedOut
+	}
+
+	if checkIn == nil || checkOut == nil {
+		return 0
+	}
+
+	return math.Abs(checkOut.Sub(*checkIn).Hours() - attendance.TotalHours)
+}
+
+func canReviewStep(reviewer models.User, step models.ApprovalStep) bool {
+	if step.ReviewerID != nil {
+		return *step.ReviewerID == reviewer.ID
+	}
+	return step.ReviewerRole != "" && step.ReviewerRole == reviewer.Role
+}
+
+// ------- the single admin review of corrections from before approval chains. escalation can hand
+// one to a manager through assigned_to, that reviewer takes the admins' place
+func legacyStep(correction models.Correction) models.ApprovalStep {
+	step := models.ApprovalStep{Name: "Admin review", ReviewerType: "role", ReviewerRole: "admin", Decision: "pending"}
+	if correction.AssignedTo != nil {
+		step.ReviewerType = "user"
+		step.ReviewerID = correction.AssignedTo
+		step.ReviewerRole = ""
+	} else if correction.AssignedRole != "" {
+		step.ReviewerRole = correction.AssignedRole
+	}
+	return step
+}
+
+// what Get_pending_corrections and the reminder job use to find who the current step waits on
+func stepAssignment(step models.ApprovalStep) bson.M {
+	if step.ReviewerID != nil {
+		return bson.M{"assigned_to": step.ReviewerID, "assigned_role": ""}
+	}
+	return bson.M{"assigned_to": nil, "assigned_role": step.ReviewerRole}
+}
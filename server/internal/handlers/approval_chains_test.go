@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Sourav01112/server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChainMatches(t *testing.T) {
+	sales := models.User{Group: "sales"}
+	noGroup := models.User{}
+
+	tests := []struct {
+		name         string
+		rules        models.ChainRules
+		employee     models.User
+		hoursChanged float64
+		ageDays      int
+		want         bool
+	}{
+		{"no rules match everything", models.ChainRules{}, noGroup, 0, 0, true},
+		{"hours changed above the minimum", models.ChainRules{MinHoursChanged: 2}, sales, 2.5, 0, true},
+		{"hours changed at the minimum", models.ChainRules{MinHoursChanged: 2}, sales, 2, 0, false},
+		{"hours changed below the minimum", models.ChainRules{MinHoursChanged: 2}, sales, 0.5, 0, false},
+		{"attendance at the minimum age", models.ChainRules{MinAttendanceAgeDays: 7}, sales, 0, 7, true},
+		{"attendance younger than the minimum age", models.ChainRules{MinAttendanceAgeDays: 7}, sales, 0, 6, false},
+		{"employee in one of the groups", models.ChainRules{Groups: []string{"ops", "sales"}}, sales, 0, 0, true},
+		{"employee in another group", models.ChainRules{Groups: []string{"ops"}}, sales, 0, 0, false},
+		{"employee without a group", models.ChainRules{Groups: []string{"ops"}}, noGroup, 0, 0, false},
+		{"all rules hold", models.ChainRules{MinHoursChanged: 1, MinAttendanceAgeDays: 3, Groups: []string{"sales"}}, sales, 4, 10, true},
+		{"group matches but the hours don't", models.ChainRules{MinHoursChanged: 1, Groups: []string{"sales"}}, sales, 0.5, 10, false},
+		{"hours match but the age doesn't", models.ChainRules{MinHoursChanged: 1, MinAttendanceAgeDays: 3}, sales, 4, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chainMatches(tt.rules, tt.employee, tt.hoursChanged, tt.ageDays); got != tt.want {
+				t.Errorf("chainMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveStep(t *testing.T) {
+	managerID := primitive.NewObjectID()
+	reviewerID := primitive.NewObjectID()
+
+	managed := models.User{ID: primitive.NewObjectID(), ManagerID: &managerID}
+	unmanaged := models.User{ID: primitive.NewObjectID()}
+
+	tests := []struct {
+		name     string
+		step     models.ChainStep
+		employee models.User
+		want     models.ApprovalStep
+	}{
+		{
+			name:     "role step",
+			step:     models.ChainStep{Name: "HR", ReviewerType: "role", ReviewerRole: models.RoleHR},
+			employee: managed,
+			want:     models.ApprovalStep{Name: "HR", ReviewerType: "role", ReviewerRole: models.RoleHR, Decision: "waiting"},
+		},
+		{
+			name:     "user step",
+			step:     models.ChainStep{Name: "Payroll", ReviewerType: "user", ReviewerID: &reviewerID},
+			employee: managed,
+			want:     models.ApprovalStep{Name: "Payroll", ReviewerType: "user", ReviewerID: &reviewerID, Decision: "waiting"},
+		},
+		{
+			name:     "manager step goes to the employee's manager",
+			step:     models.ChainStep{Name: "Manager", ReviewerType: "manager"},
+			employee: managed,
+			want:     models.ApprovalStep{Name: "Manager", ReviewerType: "manager", ReviewerID: &managerID, Decision: "waiting"},
+		},
+		{
+			name:     "manager step without a manager goes to the admins",
+			step:     models.ChainStep{Name: "Manager", ReviewerType: "manager"},
+			employee: unmanaged,
+			want:     models.ApprovalStep{Name: "Manager", ReviewerType: "manager", ReviewerRole: models.RoleAdmin, Decision: "waiting"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveStep(tt.step, tt.employee); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveStep() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
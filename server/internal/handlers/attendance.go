@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
@@ -12,9 +13,28 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var (
+	errAlreadyCheckedIn      = errors.New("already checked in")
+	errNoCheckIn             = errors.New("no active check-in")
+	errAlreadyCheckedOut     = errors.New("already checked out")
+	errCheckOutBeforeCheckIn = errors.New("check-out before check-in")
+)
+
+// ------- one check-in or check-out that passed the policy checks, Flags are what those checks found
+type punch struct {
+	Kind     string // ---------------- check_in-check_out
+	At       time.Time
+	Location models.Location
+	DeviceID string
+	IP       string
+	KioskID  *primitive.ObjectID
+	Flags    []models.AttendanceFlag
+}
+
 func Check_In(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 
@@ -24,102 +44,257 @@ func Check_In(c *gin.Context) {
 		return
 	}
 
-	today := time.Now().Format("2006-01-02")
+	flags, ok := checkDevice(c, user, req.DeviceID, "check_in")
+	if !ok {
+		return
+	}
+
+	networkFlags, ok := checkNetwork(c, user, "check_in")
+	if !ok {
+		return
+	}
+
+	_, err := recordPunch(context.TODO(), user, punch{
+		Kind:     "check_in",
+		At:       time.Now(),
+		Location: req.Location,
+		DeviceID: req.DeviceID,
+		IP:       c.ClientIP(),
+		Flags:    append(flags, networkFlags...),
+	})
+	if err != nil {
+		punchErrorResponse(c, err, "Failed to record check-in")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Check-in recorded successfully"})
+}
+
+func Check_out(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.CheckOutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	flags, ok := checkDevice(c, user, req.DeviceID, "check_out")
+	if !ok {
+		return
+	}
+
+	networkFlags, ok := checkNetwork(c, user, "check_out")
+	if !ok {
+		return
+	}
+
+	_, err := recordPunch(context.TODO(), user, punch{
+		Kind:     "check_out",
+		At:       time.Now(),
+		Location: req.Location,
+		DeviceID: req.DeviceID,
+		IP:       c.ClientIP(),
+		Flags:    append(flags, networkFlags...),
+	})
+	if err != nil {
+		punchErrorResponse(c, err, "Failed to record check-out")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Check-out recorded successfully"})
+}
+
+// ------- records p.Kind and returns every flag the record got for it. the location checks run here
+// so every way of punching gets them
+func recordPunch(ctx context.Context, user models.User, p punch) ([]models.AttendanceFlag, error) {
+	if p.Kind == "check_out" {
+		return recordCheckOut(ctx, user, p)
+	}
+	return recordCheckIn(ctx, user, p)
+}
+
+func recordCheckIn(ctx context.Context, user models.User, p punch) ([]models.AttendanceFlag, error) {
+	day := p.At.Format("2006-01-02")
+
+	if msg := employmentDateError(user, day); msg != "" {
+		return nil, &employmentDateErr{msg}
+	}
+
+	flags, err := punchFlags(ctx, user, p)
+	if err != nil {
+		return nil, err
+	}
 
 	// ------- if already in today
 	var existingAttendance models.Attendance
-	err := config.DB.Collection("attendance").FindOne(context.TODO(), bson.M{
+	err = config.DB.Collection("attendance").FindOne(ctx, bson.M{
 		"user_id": user.ID,
-		"date":    today,
+		"date":    day,
 	}).Decode(&existingAttendance)
 
 	if err == nil && existingAttendance.CheckIn != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Already checked in today")
-		return
+		return nil, errAlreadyCheckedIn
 	}
 
 	now := time.Now()
 	attendance := models.Attendance{
 		UserID:     user.ID,
-		Date:       today,
-		CheckIn:    &now,
-		CheckInLoc: &req.Location,
+		Date:       day,
+		CheckIn:    &p.At,
+		CheckInLoc: &p.Location,
 		Status:     "pending",
 		CreatedAt:  now,
 		UpdatedAt:  now,
+
+		CheckInDevice: p.DeviceID,
+		CheckInIP:     p.IP,
+		CheckInKiosk:  p.KioskID,
+		Flags:         flags,
+	}
+	if len(flags) > 0 {
+		attendance.FlagReview = "pending"
 	}
 
+	// ------- a second tap racing this one loses on the check_in filter or the unique (user_id, date) index
 	if err == nil {
-		_, err = config.DB.Collection("attendance").UpdateOne(
-			context.TODO(),
-			bson.M{"_id": existingAttendance.ID},
-			bson.M{"$set": bson.M{
+		var result *mongo.UpdateResult
+		result, err = config.DB.Collection("attendance").UpdateOne(
+			ctx,
+			bson.M{"_id": existingAttendance.ID, "check_in": nil},
+			withFlags(bson.M{"$set": bson.M{
 				"check_in":          attendance.CheckIn,
 				"check_in_location": attendance.CheckInLoc,
+				"check_in_device":   attendance.CheckInDevice,
+				"check_in_ip":       attendance.CheckInIP,
+				"check_in_kiosk":    attendance.CheckInKiosk,
 				"status":            "pending",
 				"updated_at":        now,
-			}},
+			}}, flags),
 		)
+		if err == nil && result.MatchedCount == 0 {
+			return nil, errAlreadyCheckedIn
+		}
 	} else {
-		_, err = config.DB.Collection("attendance").InsertOne(context.TODO(), attendance)
+		_, err = config.DB.Collection("attendance").InsertOne(ctx, attendance)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errAlreadyCheckedIn
+		}
 	}
-
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record check-in")
-		return
+		return nil, err
 	}
 
-	utils.SuccessResponse(c, gin.H{"message": "Check-in recorded successfully"})
+	return flags, nil
 }
 
-func Check_out(c *gin.Context) {
-	user := c.MustGet("user").(models.User)
+func recordCheckOut(ctx context.Context, user models.User, p punch) ([]models.AttendanceFlag, error) {
+	day := p.At.Format("2006-01-02")
 
-	var req models.CheckOutRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
-		return
+	if msg := employmentDateError(user, day); msg != "" {
+		return nil, &employmentDateErr{msg}
 	}
 
-	today := time.Now().Format("2006-01-02")
+	flags, err := punchFlags(ctx, user, p)
+	if err != nil {
+		return nil, err
+	}
 
 	var attendance models.Attendance
-	err := config.DB.Collection("attendance").FindOne(context.TODO(), bson.M{
+	err = config.DB.Collection("attendance").FindOne(ctx, bson.M{
 		"user_id": user.ID,
-		"date":    today,
+		"date":    day,
 	}).Decode(&attendance)
 
 	if err != nil || attendance.CheckIn == nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "No active check-in found")
-		return
+		return nil, errNoCheckIn
 	}
 
 	if attendance.CheckOut != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Already checked out today")
-		return
+		return nil, errAlreadyCheckedOut
 	}
 
-	now := time.Now()
-	totalHours := now.Sub(*attendance.CheckIn).Hours()
-
-	_, err = config.DB.Collection("attendance").UpdateOne(
-		context.TODO(),
-		bson.M{"_id": attendance.ID},
-		bson.M{"$set": bson.M{
-			"check_out":          &now,
-			"check_out_location": &req.Location,
+	if !p.At.After(*attendance.CheckIn) {
+		return nil, errCheckOutBeforeCheckIn
+	}
+
+	totalHours := p.At.Sub(*attendance.CheckIn).Hours()
+
+	result, err := config.DB.Collection("attendance").UpdateOne(
+		ctx,
+		bson.M{"_id": attendance.ID, "check_out": nil},
+		withFlags(bson.M{"$set": bson.M{
+			"check_out":          &p.At,
+			"check_out_location": &p.Location,
+			"check_out_device":   p.DeviceID,
+			"check_out_ip":       p.IP,
+			"check_out_kiosk":    p.KioskID,
 			"total_hours":        totalHours,
 			"status":             "valid",
-			"updated_at":         now,
-		}},
+			"updated_at":         time.Now(),
+		}}, flags),
 	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, errAlreadyCheckedOut
+	}
 
+	return flags, nil
+}
+
+// ------- a kiosk's location is fixed, not a device fix, so the location checks would only see repeats
+func punchFlags(ctx context.Context, user models.User, p punch) ([]models.AttendanceFlag, error) {
+	if p.KioskID != nil {
+		return p.Flags, nil
+	}
+
+	locFlags, err := locationFlags(ctx, user.ID, p.Kind, p.Location, p.At)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record check-out")
-		return
+		return nil, err
 	}
+	return append(p.Flags, locFlags...), nil
+}
 
-	utils.SuccessResponse(c, gin.H{"message": "Check-out recorded successfully"})
+// adds the punch's flags to an attendance update, which puts the record (back) in the review queue
+func withFlags(update bson.M, flags []models.AttendanceFlag) bson.M {
+	if len(flags) > 0 {
+		update["$push"] = bson.M{"flags": bson.M{"$each": flags}}
+		update["$set"].(bson.M)["flag_review"] = "pending"
+	}
+	return update
+}
+
+type employmentDateErr struct {
+	message string
+}
+
+func (e *employmentDateErr) Error() string {
+	return e.message
+}
+
+func punchErrorResponse(c *gin.Context, err error, fallback string) {
+	status, message := punchError(err, fallback)
+	utils.ErrorResponse(c, status, message)
+}
+
+func punchError(err error, fallback string) (int, string) {
+	var dateErr *employmentDateErr
+	switch {
+	case errors.As(err, &dateErr):
+		return http.StatusForbidden, dateErr.message
+	case errors.Is(err, errAlreadyCheckedIn):
+		return http.StatusBadRequest, "Already checked in today"
+	case errors.Is(err, errNoCheckIn):
+		return http.StatusBadRequest, "No active check-in found"
+	case errors.Is(err, errAlreadyCheckedOut):
+		return http.StatusBadRequest, "Already checked out today"
+	case errors.Is(err, errCheckOutBeforeCheckIn):
+		return http.StatusBadRequest, "Check-out can't be before the check-in"
+	default:
+		return http.StatusInternalServerError, fallback
+	}
 }
 
 func Get_individual_attendance(c *gin.Context) {
@@ -169,10 +344,20 @@ func Request_correction(c *gin.Context) {
 		return
 	}
 
+	fieldErrors, err := validateCorrectionTimes(context.TODO(), attendance, req.RequestedCheckIn, req.RequestedCheckOut)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate correction")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid correction times", fieldErrors)
+		return
+	}
+
 	var existingCorrection models.Correction
 	err = config.DB.Collection("corrections").FindOne(context.TODO(), bson.M{
 		"attendance_id": attendanceID,
-		"status":        "pending",
+		"status":        bson.M{"$in": bson.A{"pending", "needs_info"}},
 	}).Decode(&existingCorrection)
 
 	if err == nil {
@@ -180,6 +365,12 @@ func Request_correction(c *gin.Context) {
 		return
 	}
 
+	chainID, steps, err := selectApprovalSteps(context.TODO(), user, attendance, req.RequestedCheckIn, req.RequestedCheckOut)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to select approval chain")
+		return
+	}
+
 	now := time.Now()
 
 	// ------- as per requirement from sheet...the correction window will be time bound, take 48hr, later change
@@ -192,6 +383,16 @@ func Request_correction(c *gin.Context) {
 		Status:            "pending",
 		CreatedAt:         now,
 		ExpiresAt:         now.Add(48 * time.Hour),
+		ChainID:           chainID,
+		Steps:             steps,
+		CurrentStep:       0,
+		StepStartedAt:     &now,
+	}
+
+	if steps[0].ReviewerID != nil {
+		correction.AssignedTo = steps[0].ReviewerID
+	} else {
+		correction.AssignedRole = steps[0].ReviewerRole
 	}
 
 	_, err = config.DB.Collection("corrections").InsertOne(context.TODO(), correction)
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/anomaly"
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// how many earlier attendance days the anomaly checks look at
+const anomalyHistoryDays = 10
+
+// ------- flagged attendance waiting for a decision, takes the team attendance filters plus
+// ?flag_review=cleared|rejected for decided records and ?flag=<type>
+func Get_attendance_reviews(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	filter, ok := teamAttendanceFilter(c)
+	if !ok {
+		return
+	}
+	filter["flag_review"] = c.DefaultQuery("flag_review", "pending")
+
+	page, limit := pagination(c)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := config.DB.Collection("attendance").Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attendance")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	attendances := []models.Attendance{}
+	if err = cursor.All(context.TODO(), &attendances); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode attendance")
+		return
+	}
+
+	total, err := config.DB.Collection("attendance").CountDocuments(context.TODO(), filter)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count attendance")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"attendance": attendances, "total": total, "page": page, "limit": limit})
+}
+
+// ------- cleared keeps the record as it is, rejected marks it invalid
+func Review_attendance_flags(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	attendanceID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid attendance ID")
+		return
+	}
+
+	var req models.FlagReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if !slices.Contains(models.FlagReviewDecisions, req.Decision) {
+		utils.ValidationErrorResponse(c, "Invalid review", []utils.FieldError{{Field: "decision", Message: "must be one of " + strings.Join(models.FlagReviewDecisions, ", ")}})
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"flag_review": req.Decision,
+		"reviewed_by": user.ID,
+		"reviewed_at": &now,
+		"review_note": req.Note,
+		"updated_at":  now,
+	}
+	if req.Decision == "rejected" {
+		set["status"] = "invalid"
+	}
+
+	// ------- only pending records, so a flag added after someone opened the record isn't decided blind
+	var attendance models.Attendance
+	err = config.DB.Collection("attendance").FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"_id": attendanceID, "flag_review": "pending"},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&attendance)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "No pending review for this attendance")
+		return
+	}
+
+	if err := recordAudit(context.TODO(), user, "attendance.flags_"+req.Decision, "attendance", attendance.ID, map[string]any{"note": req.Note}); err != nil {
+		logAuditFailure("attendance.flags_"+req.Decision, attendance.ID, err)
+	}
+
+	utils.SuccessResponse(c, attendance)
+}
+
+// ------- runs the anomaly checks against the user's latest punches
+func locationFlags(ctx context.Context, userID primitive.ObjectID, punch string, loc models.Location, now time.Time) ([]models.AttendanceFlag, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}}).SetLimit(anomalyHistoryDays)
+	cursor, err := config.DB.Collection("attendance").Find(ctx, bson.M{"user_id": userID, "date": bson.M{"$lte": now.Format("2006-01-02")}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []models.Attendance
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	// ------- punches synced late can be older than what is already recorded, they are compared with what came before
+	var history []anomaly.Punch
+	for _, record := range records {
+		if record.CheckIn != nil && record.CheckInLoc != nil && record.CheckIn.Before(now) {
+			history = append(history, anomaly.Punch{At: *record.CheckIn, Location: *record.CheckInLoc})
+		}
+		if record.CheckOut != nil && record.CheckOutLoc != nil && record.CheckOut.Before(now) {
+			history = append(history, anomaly.Punch{At: *record.CheckOut, Location: *record.CheckOutLoc})
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].At.After(history[j].At) })
+
+	return anomaly.Detect(punch, loc, now, history, anomaly.LimitsFromEnv()), nil
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pass the session context when inside a transaction so the entry commits or rolls back with the change
+func recordAudit(ctx context.Context, actor models.User, action string, entityType string, entityID primitive.ObjectID, details map[string]any) error {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		ActorID:    actor.ID,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}
+
+	_, err := config.DB.Collection("audit_logs").InsertOne(ctx, entry)
+	return err
+}
+
+func logAuditFailure(action string, entityID primitive.ObjectID, err error) {
+	log.Printf("Failed to record audit %s for %s: %v", action, entityID.Hex(), err)
+}
@@ -2,18 +2,19 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/Sourav01112/server/internal/config"
 	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/tokens"
 	"github.com/Sourav01112/server/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -33,34 +34,77 @@ func Login(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
 		return
 	}
-
-	fmt.Println("reqqq", req)
+	req.Email = normalizeEmail(req.Email)
 
 	var user models.User
 	err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"email": req.Email}).Decode(&user)
-	if err != nil {
-		fmt.Println("two")
-
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	if err != nil && err != mongo.ErrNoDocuments {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load user")
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+	existing := &user
+	if err == mongo.ErrNoDocuments {
+		existing = nil
+	}
+
+	// ------- directory users bind against LDAP instead of the stored password
+	dir, err := loginDirectory(context.TODO(), existing, req.Email)
 	if err != nil {
-		fmt.Println("three", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load directory")
+		return
+	}
+
+	if dir != nil {
+		user, err = ldapLogin(context.TODO(), *dir, req.Email, req.Password)
+		if isLoginRejection(err) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+		if err != nil {
+			log.Printf("LDAP login against %s failed: %v", dir.Name, err)
+			utils.ErrorResponse(c, http.StatusServiceUnavailable, "Directory is unavailable, try again later")
+			return
+		}
+	} else {
+		if existing == nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
 
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+		if user.Status == "invited" {
+			utils.ErrorResponse(c, http.StatusForbidden, "Account not activated, use the link in your invitation email")
+			return
+		}
+
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+	}
+
+	if user.Status == "deactivated" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account is deactivated")
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"email":   user.Email,
-		"role":    user.Role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-	})
+	// ------- no token yet when a second factor is needed, only a short lived one for /auth/2fa/*
+	if user.TwoFactor.Enabled || twoFactorRequired(user) {
+		mfaToken, err := issueMFAToken(user, loginMethod(user))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+		utils.SuccessResponse(c, models.TwoFactorChallenge{
+			MFAToken:      mfaToken,
+			SetupRequired: !user.TwoFactor.Enabled,
+		})
+		return
+	}
+
+	tokenString, err := issueToken(c, user, loginMethod(user))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -71,3 +115,27 @@ func Login(c *gin.Context) {
 		User:  user,
 	})
 }
+
+// ------- every token belongs to a session, its id goes in as "sid"
+func issueToken(c *gin.Context, user models.User, method string) (string, error) {
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	session, err := createSession(c, user, method, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return tokens.Sign(jwt.MapClaims{
+		"user_id": user.ID.Hex(),
+		"sid":     session.ID.Hex(),
+		"email":   user.Email,
+		"role":    user.Role,
+		"exp":     expiresAt.Unix(),
+	})
+}
+
+// ------- public keys for other services verifying our tokens, old keys stay listed until retired
+func Get_jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, tokens.JWKS())
+}
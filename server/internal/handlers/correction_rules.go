@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	maxShiftDuration = 16 * time.Hour
+	// ------- night shifts can check out on the next calendar day until this hour
+	shiftDayCutoff = 6 * time.Hour
+)
+
+type correctionValidationError struct {
+	Errors []utils.FieldError
+}
+
+func (e *correctionValidationError) Error() string {
+	return "invalid correction times"
+}
+
+// checks the times a correction would leave on the attendance record, missing requested times fall back to the recorded ones
+func validateCorrectionTimes(ctx context.Context, attendance models.Attendance, requestedIn, requestedOut *time.Time) ([]utils.FieldError, error) {
+	errs, err := correctionTimeErrors(attendance, requestedIn, requestedOut, time.Now())
+	if err != nil || len(errs) > 0 {
+		return errs, err
+	}
+
+	checkIn, checkOut := correctedTimes(attendance, requestedIn, requestedOut)
+	dayStart, _ := time.ParseInLocation("2006-01-02", attendance.Date, time.Local)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	// ------- other sessions of the same user must not overlap the corrected one
+	if checkIn != nil {
+		end := dayEnd.Add(shiftDayCutoff)
+		if checkOut != nil {
+			end = *checkOut
+		}
+
+		overlap := bson.M{
+			"user_id":  attendance.UserID,
+			"_id":      bson.M{"$ne": attendance.ID},
+			"check_in": bson.M{"$lt": end},
+			"$or": bson.A{
+				bson.M{"check_out": bson.M{"$gt": *checkIn}},
+				bson.M{"check_out": nil, "check_in": bson.M{"$gte": *checkIn}},
+			},
+		}
+
+		count, err := config.DB.Collection("attendance").CountDocuments(ctx, overlap)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			field := "requested_check_in"
+			if requestedIn == nil {
+				field = "requested_check_out"
+			}
+			errs = append(errs, utils.FieldError{Field: field, Message: "overlaps another attendance session"})
+		}
+	}
+
+	return errs, nil
+}
+
+// ------- the checks that don't need the database: ordering, the attendance day and the shift length
+func correctionTimeErrors(attendance models.Attendance, requestedIn, requestedOut *time.Time, now time.Time) ([]utils.FieldError, error) {
+	var errs []utils.FieldError
+
+	if requestedIn == nil && requestedOut == nil {
+		errs = append(errs, utils.FieldError{Field: "requested_check_in", Message: "at least one of requested_check_in or requested_check_out is required"})
+		return errs, nil
+	}
+
+	checkIn, checkOut := correctedTimes(attendance, requestedIn, requestedOut)
+
+	if requestedIn != nil && requestedIn.After(now) {
+		errs = append(errs, utils.FieldError{Field: "requested_check_in", Message: "cannot be in the future"})
+	}
+	if requestedOut != nil && requestedOut.After(now) {
+		errs = append(errs, utils.FieldError{Field: "requested_check_out", Message: "cannot be in the future"})
+	}
+
+	dayStart, err := time.ParseInLocation("2006-01-02", attendance.Date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("attendance %s has invalid date %q: %w", attendance.ID.Hex(), attendance.Date, err)
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	if requestedIn != nil && (requestedIn.Before(dayStart) || !requestedIn.Before(dayEnd)) {
+		errs = append(errs, utils.FieldError{Field: "requested_check_in", Message: "must be on the attendance date " + attendance.Date})
+	}
+	if requestedOut != nil && (requestedOut.Before(dayStart) || requestedOut.After(dayEnd.Add(shiftDayCutoff))) {
+		errs = append(errs, utils.FieldError{Field: "requested_check_out", Message: "must fall within the shift day of " + attendance.Date})
+	}
+
+	if checkIn != nil && checkOut != nil {
+		field := "requested_check_out"
+		if requestedOut == nil {
+			field = "requested_check_in"
+		}
+
+		if !checkOut.After(*checkIn) {
+			errs = append(errs, utils.FieldError{Field: field, Message: "check-out must be after check-in"})
+		} else if checkOut.Sub(*checkIn) > maxShiftDuration {
+			errs = append(errs, utils.FieldError{Field: field, Message: fmt.Sprintf("shift cannot be longer than %v", maxShiftDuration)})
+		}
+	}
+
+	return errs, nil
+}
+
+func correctedTimes(attendance models.Attendance, requestedIn, requestedOut *time.Time) (*time.Time, *time.Time) {
+	checkIn := attendance.CheckIn
+	if requestedIn != nil {
+		checkIn = requestedIn
+	}
+	checkOut := attendance.CheckOut
+	if requestedOut != nil {
+		checkOut = requestedOut
+	}
+	return checkIn, checkOut
+}
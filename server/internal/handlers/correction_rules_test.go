@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+)
+
+func TestCorrectionTimeErrors(t *testing.T) {
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	at := func(dayOffset int, hour int, minute int) *time.Time {
+		return ptrTime(day.AddDate(0, 0, dayOffset).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute))
+	}
+	now := *at(1, 12, 0)
+
+	recorded := models.Attendance{Date: "2026-10-19", CheckIn: at(0, 9, 0), CheckOut: at(0, 18, 0)}
+	open := models.Attendance{Date: "2026-10-19", CheckIn: at(0, 9, 0)}
+	empty := models.Attendance{Date: "2026-10-19"}
+
+	tests := []struct {
+		name         string
+		attendance   models.Attendance
+		requestedIn  *time.Time
+		requestedOut *time.Time
+		want         []utils.FieldError
+	}{
+		{
+			name:       "nothing requested",
+			attendance: recorded,
+			want:       []utils.FieldError{{Field: "requested_check_in", Message: "at least one of requested_check_in or requested_check_out is required"}},
+		},
+		{
+			name:         "both times on the day",
+			attendance:   empty,
+			requestedIn:  at(0, 8, 30),
+			requestedOut: at(0, 17, 30),
+		},
+		{
+			name:         "missing check-out of an open record",
+			attendance:   open,
+			requestedOut: at(0, 18, 0),
+		},
+		{
+			name:        "check-in moved earlier, recorded check-out kept",
+			attendance:  recorded,
+			requestedIn: at(0, 8, 0),
+		},
+		{
+			name:         "check-out before check-in",
+			attendance:   empty,
+			requestedIn:  at(0, 18, 0),
+			requestedOut: at(0, 9, 0),
+			want:         []utils.FieldError{{Field: "requested_check_out", Message: "check-out must be after check-in"}},
+		},
+		{
+			name:         "check-out at the check-in",
+			attendance:   empty,
+			requestedIn:  at(0, 9, 0),
+			requestedOut: at(0, 9, 0),
+			want:         []utils.FieldError{{Field: "requested_check_out", Message: "check-out must be after check-in"}},
+		},
+		{
+			name:        "check-in after the recorded check-out",
+			attendance:  recorded,
+			requestedIn: at(0, 19, 0),
+			want:        []utils.FieldError{{Field: "requested_check_in", Message: "check-out must be after check-in"}},
+		},
+		{
+			name:        "check-in on the day before",
+			attendance:  open,
+			requestedIn: at(-1, 23, 0),
+			want:        []utils.FieldError{{Field: "requested_check_in", Message: "must be on the attendance date 2026-10-19"}},
+		},
+		{
+			name:        "check-in on the day after",
+			attendance:  empty,
+			requestedIn: at(1, 0, 0),
+			want:        []utils.FieldError{{Field: "requested_check_in", Message: "must be on the attendance date 2026-10-19"}},
+		},
+		{
+			name:         "night shift checks out the next morning",
+			attendance:   empty,
+			requestedIn:  at(0, 22, 0),
+			requestedOut: at(1, 6, 0),
+		},
+		{
+			name:         "check-out past the shift day cutoff",
+			attendance:   empty,
+			requestedIn:  at(0, 22, 0),
+			requestedOut: at(1, 6, 1),
+			want:         []utils.FieldError{{Field: "requested_check_out", Message: "must fall within the shift day of 2026-10-19"}},
+		},
+		{
+			name:         "shift of exactly 16 hours",
+			attendance:   empty,
+			requestedIn:  at(0, 6, 0),
+			requestedOut: at(0, 22, 0),
+		},
+		{
+			name:         "shift longer than 16 hours",
+			attendance:   empty,
+			requestedIn:  at(0, 6, 0),
+			requestedOut: at(0, 22, 1),
+			want:         []utils.FieldError{{Field: "requested_check_out", Message: "shift cannot be longer than 16h0m0s"}},
+		},
+		{
+			name:        "check-in in the future",
+			attendance:  models.Attendance{Date: "2026-10-20"},
+			requestedIn: at(1, 13, 0),
+			want:        []utils.FieldError{{Field: "requested_check_in", Message: "cannot be in the future"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := correctionTimeErrors(tt.attendance, tt.requestedIn, tt.requestedOut, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("correctionTimeErrors() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorrectionTimeErrorsInvalidDate(t *testing.T) {
+	in := time.Now().Add(-time.Hour)
+	if _, err := correctionTimeErrors(models.Attendance{Date: "19.10.2026"}, &in, nil, time.Now()); err == nil {
+		t.Error("correctionTimeErrors() with an invalid attendance date returned no error")
+	}
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/storage"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxAttachmentsPerCorrection = 10
+
+// ------- extension -> content type we store, and the sniffed type the bytes have to match
+var allowedAttachments = map[string]struct {
+	contentType string
+	sniffed     string
+}{
+	".pdf":  {"application/pdf", "application/pdf"},
+	".png":  {"image/png", "image/png"},
+	".jpg":  {"image/jpeg", "image/jpeg"},
+	".jpeg": {"image/jpeg", "image/jpeg"},
+	".ics":  {"text/calendar", "text/plain"},
+	".eml":  {"message/rfc822", "text/plain"},
+	".txt":  {"text/plain", "text/plain"},
+}
+
+func Get_correction(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	correction, ok := loadAccessibleCorrection(c, user)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, correction)
+}
+
+// ------- reviewer of the current step asks the employee for more details, pauses the SLA clock until they reply
+func Request_correction_info(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.ThreadMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Message is required")
+		return
+	}
+
+	correction, ok := loadAccessibleCorrection(c, user)
+	if !ok {
+		return
+	}
+
+	if correction.Status != "pending" {
+		utils.ErrorResponse(c, http.StatusConflict, "Correction is not waiting for review")
+		return
+	}
+
+	if !isCurrentReviewer(user, correction) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not the reviewer for the current approval step")
+		return
+	}
+
+	filter := bson.M{"_id": correction.ID, "status": "pending"}
+	if len(correction.Steps) > 0 {
+		filter["current_step"] = correction.CurrentStep
+	}
+
+	err := config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		result, err := config.DB.Collection("corrections").UpdateOne(sc, filter, bson.M{
+			"$set":  bson.M{"status": "needs_info"},
+			"$push": bson.M{"thread": newThreadMessage(user, "info_request", req.Message)},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errCorrectionConflict
+		}
+
+		return recordAudit(sc, user, "correction.info_requested", "correction", correction.ID, map[string]any{"message": req.Message})
+	})
+	if err != nil {
+		reviewErrorResponse(c, err, "Failed to request information")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Information requested from employee"})
+}
+
+// ------- anyone on the correction can comment, the employee's reply to a needs_info puts it back in the queue
+func Add_correction_comment(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.ThreadMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Message is required")
+		return
+	}
+
+	correction, ok := loadAccessibleCorrection(c, user)
+	if !ok {
+		return
+	}
+
+	if correction.Status != "pending" && correction.Status != "needs_info" {
+		utils.ErrorResponse(c, http.StatusConflict, "Correction already processed")
+		return
+	}
+
+	kind := "comment"
+	filter := bson.M{"_id": correction.ID, "status": correction.Status}
+	update := bson.M{}
+
+	if correction.Status == "needs_info" && user.ID == correction.UserID {
+		now := time.Now()
+		kind = "info_reply"
+		update["$set"] = bson.M{
+			"status":          "pending",
+			"step_started_at": &now,
+			"reminded_at":     nil,
+		}
+	}
+	update["$push"] = bson.M{"thread": newThreadMessage(user, kind, req.Message)}
+
+	result, err := config.DB.Collection("corrections").UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to add comment")
+		return
+	}
+	if result.MatchedCount == 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Correction changed, reload and try again")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Comment added successfully"})
+}
+
+func Upload_correction_attachment(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	maxBytes := attachmentMaxBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	correction, ok := loadAccessibleCorrection(c, user)
+	if !ok {
+		return
+	}
+
+	if correction.Status != "pending" && correction.Status != "needs_info" {
+		utils.ErrorResponse(c, http.StatusConflict, "Correction already processed")
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "File is required")
+		return
+	}
+
+	if header.Size > maxBytes {
+		utils.ValidationErrorResponse(c, "Invalid attachment", []utils.FieldError{{Field: "file", Message: fmt.Sprintf("must be at most %d bytes", maxBytes)}})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	allowed, ok := allowedAttachments[ext]
+	if !ok {
+		utils.ValidationErrorResponse(c, "Invalid attachment", []utils.FieldError{{Field: "file", Message: "file type " + ext + " is not allowed"}})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	if !strings.HasPrefix(http.DetectContentType(sniff[:n]), allowed.sniffed) {
+		utils.ValidationErrorResponse(c, "Invalid attachment", []utils.FieldError{{Field: "file", Message: "file content does not match its extension"}})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+
+	attachment := models.Attachment{
+		ID:          primitive.NewObjectID(),
+		FileName:    filepath.Base(header.Filename),
+		ContentType: allowed.contentType,
+		Size:        header.Size,
+		UploadedBy:  user.ID,
+		UploadedAt:  time.Now(),
+	}
+	attachment.StorageKey = "corrections/" + correction.ID.Hex() + "/" + attachment.ID.Hex() + ext
+
+	if err := storage.Files.Put(context.TODO(), attachment.StorageKey, file, header.Size, attachment.ContentType); err != nil {
+		log.Printf("Storing attachment for correction %s failed: %v", correction.ID.Hex(), err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+
+	// ------- the index filter caps the count without a read-modify-write race
+	result, err := config.DB.Collection("corrections").UpdateOne(
+		context.TODO(),
+		bson.M{
+			"_id":    correction.ID,
+			"status": bson.M{"$in": bson.A{"pending", "needs_info"}},
+			"attachments." + strconv.Itoa(maxAttachmentsPerCorrection-1): bson.M{"$exists": false},
+		},
+		bson.M{"$push": bson.M{"attachments": attachment}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		if delErr := storage.Files.Delete(context.TODO(), attachment.StorageKey); delErr != nil {
+			log.Printf("Cleaning up attachment %s failed: %v", attachment.StorageKey, delErr)
+		}
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save attachment")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusConflict, fmt.Sprintf("Correction is closed or already has %d attachments", maxAttachmentsPerCorrection))
+		return
+	}
+
+	utils.SuccessResponse(c, attachment)
+}
+
+func Download_correction_attachment(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	correction, ok := loadAccessibleCorrection(c, user)
+	if !ok {
+		return
+	}
+
+	attachmentID, err := primitive.ObjectIDFromHex(c.Param("attachmentId"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid attachment ID")
+		return
+	}
+
+	var attachment *models.Attachment
+	for i := range correction.Attachments {
+		if correction.Attachments[i].ID == attachmentID {
+			attachment = &correction.Attachments[i]
+			break
+		}
+	}
+	if attachment == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	body, err := storage.Files.Get(context.TODO(), attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Attachment not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to read attachment")
+		return
+	}
+	defer body.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+		"Content-Disposition":    fmt.Sprintf("attachment; filename=%q", attachment.FileName),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadAccessibleCorrection(c *gin.Context, user models.User) (models.Correction, bool) {
+	var correction models.Correction
+
+	correctionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid correction ID")
+		return correction, false
+	}
+
+	err = config.DB.Collection("corrections").FindOne(context.TODO(), bson.M{"_id": correctionID}).Decode(&correction)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Correction not found")
+		return correction, false
+	}
+
+	if !canAccessCorrection(user, correction) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return correction, false
+	}
+
+	return correction, true
+}
+
+// ------- the employee, admins, and anyone who is or was a reviewer on one of its steps
+func canAccessCorrection(user models.User, correction models.Correction) bool {
+	if user.ID == correction.UserID || user.Role == "admin" {
+		return true
+	}
+
+	for _, step := range correction.Steps {
+		if step.DecidedBy != nil && *step.DecidedBy == user.ID {
+			return true
+		}
+		if step.EscalatedFrom != nil && *step.EscalatedFrom == user.ID {
+			return true
+		}
+	}
+
+	return isCurrentReviewer(user, correction)
+}
+
+func isCurrentReviewer(user models.User, correction models.Correction) bool {
+	if user.ID == correction.UserID {
+		return false
+	}
+	if len(correction.Steps) == 0 {
+		return canReviewStep(user, legacyStep(correction))
+	}
+	if correction.CurrentStep >= len(correction.Steps) {
+		return false
+	}
+	return canReviewStep(user, correction.Steps[correction.CurrentStep])
+}
+
+func newThreadMessage(author models.User, kind string, body string) models.ThreadMessage {
+	return models.ThreadMessage{
+		ID:         primitive.NewObjectID(),
+		AuthorID:   author.ID,
+		AuthorName: author.Name,
+		Kind:       kind,
+		Body:       body,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// ------- ATTACHMENT_MAX_BYTES, defaults to 5 MB
+func attachmentMaxBytes() int64 {
+	if value := os.Getenv("ATTACHMENT_MAX_BYTES"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 5 << 20
+}
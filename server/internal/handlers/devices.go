@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	devicePending  = "pending"
+	deviceApproved = "approved"
+)
+
+func Get_my_devices(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	devices, err := userDevices(context.TODO(), user.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch devices")
+		return
+	}
+
+	utils.SuccessResponse(c, devices)
+}
+
+// ------- approved right away while under DEVICE_LIMIT, unless DEVICE_APPROVAL=admin.
+// employees can't remove devices themselves, otherwise the limit would mean nothing
+func Register_device(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.DeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	req.DeviceID = strings.TrimSpace(req.DeviceID)
+	if len(req.DeviceID) < 8 || len(req.DeviceID) > 128 {
+		utils.ValidationErrorResponse(c, "Invalid device", []utils.FieldError{{Field: "device_id", Message: "must be 8 to 128 characters"}})
+		return
+	}
+
+	var existing models.Device
+	err := config.DB.Collection("devices").FindOne(context.TODO(), bson.M{"user_id": user.ID, "device_id": req.DeviceID}).Decode(&existing)
+	if err == nil {
+		utils.SuccessResponse(c, existing)
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check devices")
+		return
+	}
+
+	reserved, err := reserveDeviceSlot(context.TODO(), user.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check devices")
+		return
+	}
+	if !reserved {
+		utils.ErrorResponse(c, http.StatusConflict, "Device limit reached, ask an admin to reset your devices")
+		return
+	}
+
+	now := time.Now()
+	device := models.Device{
+		UserID:    user.ID,
+		DeviceID:  req.DeviceID,
+		Name:      req.Name,
+		Status:    devicePending,
+		CreatedAt: now,
+	}
+	if os.Getenv("DEVICE_APPROVAL") != "admin" {
+		device.Status = deviceApproved
+		device.ApprovedAt = &now
+	}
+
+	result, err := config.DB.Collection("devices").InsertOne(context.TODO(), device)
+	if err != nil {
+		releaseDeviceSlots(context.TODO(), user.ID, 1)
+	}
+	// ------- the same device registered by a request running alongside this one
+	if mongo.IsDuplicateKeyError(err) {
+		err = config.DB.Collection("devices").FindOne(context.TODO(), bson.M{"user_id": user.ID, "device_id": req.DeviceID}).Decode(&existing)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check devices")
+			return
+		}
+		utils.SuccessResponse(c, existing)
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to register device")
+		return
+	}
+	device.ID = result.InsertedID.(primitive.ObjectID)
+
+	utils.SuccessResponse(c, device)
+}
+
+func Get_user_devices(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	devices, err := userDevices(context.TODO(), target.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch devices")
+		return
+	}
+
+	utils.SuccessResponse(c, devices)
+}
+
+func Approve_user_device(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	deviceID, err := primitive.ObjectIDFromHex(c.Param("device_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	now := time.Now()
+	var device models.Device
+	err = config.DB.Collection("devices").FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"_id": deviceID, "user_id": target.ID},
+		bson.M{"$set": bson.M{"status": deviceApproved, "approved_at": &now, "approved_by": user.ID}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&device)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Device not found")
+		return
+	}
+
+	auditUserChange(user, "user.device_approved", target.ID, map[string]any{"device_id": device.DeviceID})
+
+	utils.SuccessResponse(c, device)
+}
+
+func Remove_user_device(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	deviceID, err := primitive.ObjectIDFromHex(c.Param("device_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid device ID")
+		return
+	}
+
+	var device models.Device
+	err = config.DB.Collection("devices").FindOneAndDelete(context.TODO(), bson.M{"_id": deviceID, "user_id": target.ID}).Decode(&device)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Device not found")
+		return
+	}
+
+	releaseDeviceSlots(context.TODO(), target.ID, 1)
+	auditUserChange(user, "user.device_removed", target.ID, map[string]any{"device_id": device.DeviceID})
+
+	utils.SuccessResponse(c, gin.H{"message": "Device removed"})
+}
+
+// ------- clears every registration so the employee can register new devices
+func Reset_user_devices(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	result, err := config.DB.Collection("devices").DeleteMany(context.TODO(), bson.M{"user_id": target.ID})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset devices")
+		return
+	}
+
+	releaseDeviceSlots(context.TODO(), target.ID, result.DeletedCount)
+	auditUserChange(user, "user.devices_reset", target.ID, map[string]any{"count": result.DeletedCount})
+
+	utils.SuccessResponse(c, gin.H{"removed": result.DeletedCount})
+}
+
+// ------- DEVICE_BINDING=block refuses punches from devices that aren't approved, =flag records them with
+// an unregistered_device flag, anything else turns the check off. writes the error response itself when ok is false
+func checkDevice(c *gin.Context, user models.User, deviceID string, punch string) ([]models.AttendanceFlag, bool) {
+	flags, blocked, err := deviceFlags(context.TODO(), user, deviceID, punch)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check device")
+		return nil, false
+	}
+	if blocked != "" {
+		utils.ErrorResponse(c, http.StatusForbidden, blocked)
+		return nil, false
+	}
+	return flags, true
+}
+
+// ------- blocked is the reason the punch is refused under DEVICE_BINDING=block, empty when it may go ahead
+func deviceFlags(ctx context.Context, user models.User, deviceID string, punch string) ([]models.AttendanceFlag, string, error) {
+	policy := os.Getenv("DEVICE_BINDING")
+	if policy != "block" && policy != "flag" {
+		return nil, "", nil
+	}
+
+	reason := ""
+	if deviceID == "" {
+		reason = "no device_id sent"
+	} else {
+		var device models.Device
+		err := config.DB.Collection("devices").FindOne(ctx, bson.M{"user_id": user.ID, "device_id": deviceID}).Decode(&device)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			reason = "device is not registered"
+		case err != nil:
+			return nil, "", err
+		case device.Status != deviceApproved:
+			reason = "device is waiting for approval"
+		default:
+			now := time.Now()
+			config.DB.Collection("devices").UpdateOne(ctx, bson.M{"_id": device.ID}, bson.M{"$set": bson.M{"last_used_at": &now}})
+			return nil, "", nil
+		}
+	}
+
+	if policy == "block" {
+		return nil, "Attendance can only be recorded from a registered device: " + reason, nil
+	}
+
+	return []models.AttendanceFlag{{Type: models.FlagUnregisteredDevice, Punch: punch, Detail: reason, CreatedAt: time.Now()}}, "", nil
+}
+
+// ------- counting the devices and then inserting lets two registrations at once both pass the check.
+// device_count on the user is taken with one conditional $inc instead, users from before it start
+// at the devices they already have
+func reserveDeviceSlot(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	users := config.DB.Collection("users")
+
+	count, err := config.DB.Collection("devices").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	_, err = users.UpdateOne(ctx, bson.M{"_id": userID, "device_count": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"device_count": count}})
+	if err != nil {
+		return false, err
+	}
+
+	limit := config.IntFromEnv("DEVICE_LIMIT", 2)
+	result, err := users.UpdateOne(ctx, bson.M{"_id": userID, "device_count": bson.M{"$lt": limit}}, bson.M{"$inc": bson.M{"device_count": 1}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ------- gives slots back after devices are removed or a registration didn't insert one
+func releaseDeviceSlots(ctx context.Context, userID primitive.ObjectID, n int64) {
+	if n <= 0 {
+		return
+	}
+	remaining := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$device_count", n}}}}
+	_, err := config.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID, "device_count": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"device_count": remaining}}}},
+	)
+	if err != nil {
+		log.Printf("Releasing %d device slots of %s failed: %v", n, userID.Hex(), err)
+	}
+}
+
+func userDevices(ctx context.Context, userID primitive.ObjectID) ([]models.Device, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := config.DB.Collection("devices").Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	devices := []models.Device{}
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
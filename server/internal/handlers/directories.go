@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/directory"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/services"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Get_directories(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := config.DB.Collection("directories").Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch directories")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	dirs := []models.Directory{}
+	if err = cursor.All(context.TODO(), &dirs); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode directories")
+		return
+	}
+
+	utils.SuccessResponse(c, dirs)
+}
+
+func Create_directory(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req models.DirectoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors, err := validateDirectory(context.TODO(), &req, primitive.NilObjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check directory domains")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid directory", fieldErrors)
+		return
+	}
+
+	now := time.Now()
+	dir := directoryFromRequest(req)
+	dir.Active = req.Active == nil || *req.Active
+	dir.CreatedAt = now
+	dir.UpdatedAt = now
+
+	result, err := config.DB.Collection("directories").InsertOne(context.TODO(), dir)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create directory")
+		return
+	}
+	dir.ID = result.InsertedID.(primitive.ObjectID)
+
+	auditUserChange(user, "directory.created", dir.ID, map[string]any{"name": dir.Name})
+
+	utils.SuccessResponse(c, dir)
+}
+
+func Update_directory(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	dir, ok := loadDirectory(c)
+	if !ok {
+		return
+	}
+
+	var req models.DirectoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors, err := validateDirectory(context.TODO(), &req, dir.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check directory domains")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid directory", fieldErrors)
+		return
+	}
+
+	updated := directoryFromRequest(req)
+	updated.ID = dir.ID
+	updated.Active = dir.Active
+	if req.Active != nil {
+		updated.Active = *req.Active
+	}
+	updated.CreatedAt = dir.CreatedAt
+	updated.UpdatedAt = time.Now()
+
+	_, err = config.DB.Collection("directories").ReplaceOne(context.TODO(), bson.M{"_id": dir.ID}, updated)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update directory")
+		return
+	}
+
+	auditUserChange(user, "directory.updated", dir.ID, map[string]any{"name": updated.Name})
+
+	utils.SuccessResponse(c, updated)
+}
+
+// ------- users keep their accounts but can no longer sign in through it, an admin has to reset their password
+func Delete_directory(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	dir, ok := loadDirectory(c)
+	if !ok {
+		return
+	}
+
+	if _, err := config.DB.Collection("directories").DeleteOne(context.TODO(), bson.M{"_id": dir.ID}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete directory")
+		return
+	}
+
+	auditUserChange(user, "directory.deleted", dir.ID, map[string]any{"name": dir.Name})
+
+	utils.SuccessResponse(c, gin.H{"message": "Directory deleted successfully"})
+}
+
+// ------- runs the sync now, ?dry_run=true only fills the report
+func Sync_directory(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	dir, ok := loadDirectory(c)
+	if !ok {
+		return
+	}
+
+	report := services.SyncDirectory(context.TODO(), dir, "manual", c.Query("dry_run") == "true")
+
+	utils.SuccessResponse(c, report)
+}
+
+// ------- ?directory_id= to narrow it down, newest first
+func Get_directory_sync_reports(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	filter := bson.M{}
+	if value := c.Query("directory_id"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid directory_id")
+			return
+		}
+		filter["directory_id"] = id
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(50)
+	cursor, err := config.DB.Collection("directory_sync_reports").Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch sync reports")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	reports := []models.SyncReport{}
+	if err = cursor.All(context.TODO(), &reports); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode sync reports")
+		return
+	}
+
+	utils.SuccessResponse(c, reports)
+}
+
+// ------- LDAP users sign in against their directory, unknown emails of a directory domain are created on
+// their first successful bind. nil when the login is a normal password login
+func loginDirectory(ctx context.Context, user *models.User, email string) (*models.Directory, error) {
+	filter := bson.M{"active": true}
+	switch {
+	case user != nil && user.DirectoryID != nil:
+		filter["_id"] = *user.DirectoryID
+	case user == nil:
+		_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+		filter["domains"] = domain
+	default:
+		return nil, nil
+	}
+
+	var dir models.Directory
+	err := config.DB.Collection("directories").FindOne(ctx, filter).Decode(&dir)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dir, nil
+}
+
+func ldapLogin(ctx context.Context, dir models.Directory, email string, password string) (models.User, error) {
+	entry, err := directory.Authenticate(dir, email, password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	role, err := directory.RoleFor(dir, entry)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return provisionExternalUser(ctx, models.ExternalIdentity{
+		Provider:    "ldap",
+		ExternalID:  entry.ID,
+		DirectoryID: &dir.ID,
+		Email:       entry.Email,
+		Name:        entry.Name,
+		Role:        role,
+
+		EmailVerified: true, // ---------------- the directory is the source of truth for its users
+	})
+}
+
+func isLoginRejection(err error) bool {
+	return errors.Is(err, directory.ErrInvalidCredentials) ||
+		errors.Is(err, directory.ErrNotInGroup) ||
+		errors.Is(err, errSSONotProvisioned)
+}
+
+func validateDirectory(ctx context.Context, req *models.DirectoryRequest, except primitive.ObjectID) ([]utils.FieldError, error) {
+	var errs []utils.FieldError
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		errs = append(errs, utils.FieldError{Field: "url", Message: "must look like ldap://host:389 or ldaps://host:636"})
+	}
+
+	if req.BindDN != "" && req.BindPasswordEnv == "" {
+		errs = append(errs, utils.FieldError{Field: "bind_password_env", Message: "required with bind_dn"})
+	}
+
+	for i, domain := range req.Domains {
+		req.Domains[i] = strings.ToLower(strings.TrimSpace(domain))
+		if req.Domains[i] == "" || strings.Contains(req.Domains[i], "@") {
+			errs = append(errs, utils.FieldError{Field: "domains[" + strconv.Itoa(i) + "]", Message: "must be a domain like example.com"})
+		}
+	}
+
+	if len(req.Domains) > 0 {
+		count, err := config.DB.Collection("directories").CountDocuments(ctx, bson.M{
+			"_id":     bson.M{"$ne": except},
+			"domains": bson.M{"$in": req.Domains},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			errs = append(errs, utils.FieldError{Field: "domains", Message: "a domain can only belong to one directory"})
+		}
+	}
+
+	for i, group := range req.GroupRoles {
+		field := "group_roles[" + strconv.Itoa(i) + "]"
+		if group.GroupDN == "" {
+			errs = append(errs, utils.FieldError{Field: field + ".group_dn", Message: "is required"})
+		}
+		if !slices.Contains(models.Roles, group.Role) {
+			errs = append(errs, utils.FieldError{Field: field + ".role", Message: "must be one of " + strings.Join(models.Roles, ", ")})
+		}
+	}
+
+	if req.SyncEnabled && len(req.GroupRoles) == 0 {
+		errs = append(errs, utils.FieldError{Field: "group_roles", Message: "required when sync is enabled"})
+	}
+
+	return errs, nil
+}
+
+func directoryFromRequest(req models.DirectoryRequest) models.Directory {
+	return models.Directory{
+		Name:              req.Name,
+		Domains:           req.Domains,
+		URL:               req.URL,
+		StartTLS:          req.StartTLS,
+		BindDN:            req.BindDN,
+		BindPasswordEnv:   req.BindPasswordEnv,
+		BaseDN:            req.BaseDN,
+		UserFilter:        req.UserFilter,
+		IDAttribute:       req.IDAttribute,
+		EmailAttribute:    req.EmailAttribute,
+		NameAttribute:     req.NameAttribute,
+		MemberOfAttribute: req.MemberOfAttribute,
+		GroupRoles:        req.GroupRoles,
+		SyncEnabled:       req.SyncEnabled,
+	}
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadDirectory(c *gin.Context) (models.Directory, bool) {
+	var dir models.Directory
+
+	dirID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid directory ID")
+		return dir, false
+	}
+
+	err = config.DB.Collection("directories").FindOne(context.TODO(), bson.M{"_id": dirID}).Decode(&dir)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Directory not found")
+		return dir, false
+	}
+
+	return dir, true
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/mailer"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errInvitationInvalid = errors.New("invitation invalid or expired")
+
+// ------- public, the invitee picks their own password and the account becomes active
+func Accept_invite(c *gin.Context) {
+	var req models.AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if fieldErrors := validatePassword(req.Password); len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid password", fieldErrors)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	err = config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		now := time.Now()
+
+		// ------- used_at nil in the filter makes the token single use even with two tabs racing
+		var invitation models.Invitation
+		err := config.DB.Collection("invitations").FindOneAndUpdate(
+			sc,
+			bson.M{
+				"token_hash": hashToken(req.Token),
+				"used_at":    nil,
+				"revoked_at": nil,
+				"expires_at": bson.M{"$gt": now},
+			},
+			bson.M{"$set": bson.M{"used_at": &now}},
+		).Decode(&invitation)
+		if err == mongo.ErrNoDocuments {
+			return errInvitationInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := config.DB.Collection("users").UpdateOne(
+			sc,
+			bson.M{"_id": invitation.UserID, "status": "invited"},
+			bson.M{"$set": bson.M{
+				"password":   string(hashedPassword),
+				"status":     "active",
+				"updated_at": &now,
+			}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errInvitationInvalid
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errInvitationInvalid) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invitation is invalid or has expired")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to accept invitation")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Invitation accepted, you can now log in"})
+}
+
+// ------- ?status=pending|used|revoked|expired, defaults to pending
+func List_invitations(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if !canGrantAnyRole(user) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{}
+	switch c.DefaultQuery("status", "pending") {
+	case "pending":
+		filter = bson.M{"used_at": nil, "revoked_at": nil, "expires_at": bson.M{"$gt": now}}
+	case "used":
+		filter = bson.M{"used_at": bson.M{"$ne": nil}}
+	case "revoked":
+		filter = bson.M{"revoked_at": bson.M{"$ne": nil}}
+	case "expired":
+		filter = bson.M{"used_at": nil, "revoked_at": nil, "expires_at": bson.M{"$lte": now}}
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(200)
+	cursor, err := config.DB.Collection("invitations").Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch invitations")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	invitations := []models.Invitation{}
+	if err = cursor.All(context.TODO(), &invitations); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode invitations")
+		return
+	}
+
+	utils.SuccessResponse(c, invitations)
+}
+
+// ------- revokes whatever is still open and mails a fresh link, so only the newest link works
+func Resend_invite(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if !canGrantAnyRole(user) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if target.Status != "invited" {
+		utils.ErrorResponse(c, http.StatusConflict, "User has already accepted their invitation")
+		return
+	}
+
+	var token string
+	err := config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		if err := revokeOpenInvitations(sc, target); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = createInvitation(sc, target, user)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(sc, user, "user.invite_resent", "user", target.ID, nil)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create invitation")
+		return
+	}
+
+	if err := sendInvitation(target, token); err != nil {
+		utils.ErrorResponse(c, http.StatusBadGateway, "Invitation created but the email could not be sent")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Invitation sent"})
+}
+
+func Revoke_invite(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if !canGrantAnyRole(user) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if target.Status != "invited" {
+		utils.ErrorResponse(c, http.StatusConflict, "User has already accepted their invitation")
+		return
+	}
+
+	err := config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		if err := revokeOpenInvitations(sc, target); err != nil {
+			return err
+		}
+		return recordAudit(sc, user, "user.invite_revoked", "user", target.ID, nil)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke invitation")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Invitation revoked"})
+}
+
+func revokeOpenInvitations(ctx context.Context, invitee models.User) error {
+	now := time.Now()
+	_, err := config.DB.Collection("invitations").UpdateMany(
+		ctx,
+		bson.M{"user_id": invitee.ID, "used_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": &now}},
+	)
+	return err
+}
+
+// stores a new invitation for an invited user and returns the raw token for the email.
+// pass the session context when creating the user in the same transaction
+func createInvitation(ctx context.Context, invitee models.User, createdBy models.User) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	invitation := models.Invitation{
+		UserID:    invitee.ID,
+		Email:     invitee.Email,
+		TokenHash: hashToken(token),
+		CreatedBy: createdBy.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.DurationFromEnv("INVITE_TTL", 72*time.Hour)),
+	}
+
+	if _, err := config.DB.Collection("invitations").InsertOne(ctx, invitation); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ------- APP_BASE_URL is the client, it owns the accept-invite page that posts to /api/auth/accept-invite
+func sendInvitation(invitee models.User, token string) error {
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3001"
+	}
+
+	link := baseURL + "/accept-invite?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nYou have been invited to the attendance system. Set your password here:\n\n%s\n\nThe link can be used once and expires in %v.\n",
+		invitee.Name, link, config.DurationFromEnv("INVITE_TTL", 72*time.Hour))
+
+	if err := mailer.Send(invitee.Email, "You're invited to the attendance system", body); err != nil {
+		log.Printf("Invitation mail to %s failed: %v", invitee.Email, err)
+		return err
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/tokens"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	kioskQRPurpose      = "kiosk_qr"
+	maxKioskPINFailures = 5
+	kioskPINLockout     = 15 * time.Minute
+)
+
+var kioskPINPattern = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+func Get_kiosks(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := config.DB.Collection("kiosks").Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch kiosks")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	kiosks := []models.Kiosk{}
+	if err = cursor.All(context.TODO(), &kiosks); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode kiosks")
+		return
+	}
+
+	utils.SuccessResponse(c, kiosks)
+}
+
+// ------- the kiosk token is in this response only, it goes into the tablet's settings
+func Create_kiosk(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req models.KioskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	token := newKioskToken()
+	now := time.Now()
+	kiosk := models.Kiosk{
+		Name:      req.Name,
+		Site:      req.Site,
+		Location:  req.Location,
+		TokenHash: hashToken(token),
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	result, err := config.DB.Collection("kiosks").InsertOne(context.TODO(), kiosk)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create kiosk")
+		return
+	}
+	kiosk.ID = result.InsertedID.(primitive.ObjectID)
+
+	auditKioskChange(user, "kiosk.created", kiosk)
+
+	utils.SuccessResponse(c, gin.H{"kiosk": kiosk, "token": token})
+}
+
+func Update_kiosk(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	kiosk, ok := loadKiosk(c)
+	if !ok {
+		return
+	}
+
+	var req models.KioskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	kiosk.Name = req.Name
+	kiosk.Site = req.Site
+	kiosk.Location = req.Location
+	if req.Active != nil {
+		kiosk.Active = *req.Active
+	}
+	kiosk.UpdatedAt = time.Now()
+
+	_, err := config.DB.Collection("kiosks").UpdateOne(context.TODO(), bson.M{"_id": kiosk.ID}, bson.M{"$set": bson.M{
+		"name":       kiosk.Name,
+		"site":       kiosk.Site,
+		"location":   kiosk.Location,
+		"active":     kiosk.Active,
+		"updated_at": kiosk.UpdatedAt,
+	}})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update kiosk")
+		return
+	}
+
+	auditKioskChange(user, "kiosk.updated", kiosk)
+
+	utils.SuccessResponse(c, kiosk)
+}
+
+func Delete_kiosk(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	kiosk, ok := loadKiosk(c)
+	if !ok {
+		return
+	}
+
+	if _, err := config.DB.Collection("kiosks").DeleteOne(context.TODO(), bson.M{"_id": kiosk.ID}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete kiosk")
+		return
+	}
+
+	auditKioskChange(user, "kiosk.deleted", kiosk)
+
+	utils.SuccessResponse(c, gin.H{"message": "Kiosk deleted successfully"})
+}
+
+// ------- for a lost or reinstalled tablet, the old token stops working right away
+func Rotate_kiosk_token(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	kiosk, ok := loadKiosk(c)
+	if !ok {
+		return
+	}
+
+	token := newKioskToken()
+	_, err := config.DB.Collection("kiosks").UpdateOne(context.TODO(), bson.M{"_id": kiosk.ID}, bson.M{"$set": bson.M{
+		"token_hash": hashToken(token),
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to rotate kiosk token")
+		return
+	}
+
+	auditKioskChange(user, "kiosk.token_rotated", kiosk)
+
+	utils.SuccessResponse(c, gin.H{"kiosk": kiosk, "token": token})
+}
+
+// ------- the kiosk polls this and redraws the QR code before ExpiresAt
+func Get_kiosk_qr(c *gin.Context) {
+	kiosk := c.MustGet("kiosk").(models.Kiosk)
+
+	expiresAt := time.Now().Add(config.DurationFromEnv("KIOSK_QR_TTL", 30*time.Second))
+	code, err := tokens.Sign(jwt.MapClaims{
+		"purpose":  kioskQRPurpose,
+		"kiosk_id": kiosk.ID.Hex(),
+		"exp":      expiresAt.Unix(),
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate QR code")
+		return
+	}
+
+	utils.SuccessResponse(c, models.KioskQR{Code: code, ExpiresAt: expiresAt})
+}
+
+// ------- employee scanned the kiosk's QR code with their phone. device binding still applies,
+// the office network check doesn't since the code already proves they stand at the kiosk
+func Kiosk_scan(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.KioskScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if !validPunchAction(c, req.Action) {
+		return
+	}
+
+	claims, err := tokens.Parse(req.Code)
+	if err != nil || claims["purpose"] != kioskQRPurpose {
+		utils.ErrorResponse(c, http.StatusBadRequest, "QR code is invalid or has expired, scan again")
+		return
+	}
+
+	kioskHex, _ := claims["kiosk_id"].(string)
+	kioskID, err := primitive.ObjectIDFromHex(kioskHex)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "QR code is invalid or has expired, scan again")
+		return
+	}
+
+	var kiosk models.Kiosk
+	err = config.DB.Collection("kiosks").FindOne(context.TODO(), bson.M{"_id": kioskID, "active": true}).Decode(&kiosk)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Kiosk is no longer active")
+		return
+	}
+
+	flags, ok := checkDevice(c, user, req.DeviceID, req.Action)
+	if !ok {
+		return
+	}
+
+	kioskPunch(c, kiosk, user, req.Action, req.DeviceID, flags)
+}
+
+// ------- PIN typed on the kiosk, it punches for the employee
+func Kiosk_pin_punch(c *gin.Context) {
+	kiosk := c.MustGet("kiosk").(models.Kiosk)
+
+	var req models.KioskPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if !validPunchAction(c, req.Action) {
+		return
+	}
+
+	now := time.Now()
+	window := config.DurationFromEnv("KIOSK_PIN_FAILURE_WINDOW", 10*time.Minute)
+	if kiosk.PINFailuresSince != nil && now.Sub(*kiosk.PINFailuresSince) < window &&
+		kiosk.PINFailures >= config.IntFromEnv("KIOSK_PIN_MAX_FAILURES", 20) {
+		utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many wrong PINs on this kiosk, try again later")
+		return
+	}
+
+	var user models.User
+	err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"employee_code": req.EmployeeCode}).Decode(&user)
+	if err != nil || user.KioskPIN.Hash == "" {
+		recordKioskFailure(context.TODO(), kiosk, window)
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid employee code or PIN")
+		return
+	}
+
+	if user.KioskPIN.LockedUntil != nil && now.Before(*user.KioskPIN.LockedUntil) {
+		utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many wrong PINs, try again later")
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.KioskPIN.Hash), []byte(req.PIN)) != nil {
+		recordKioskFailure(context.TODO(), kiosk, window)
+		recordKioskPINFailure(context.TODO(), user)
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid employee code or PIN")
+		return
+	}
+
+	if user.KioskPIN.FailedAttempts > 0 {
+		config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{
+			"kiosk_pin.failed_attempts": "",
+			"kiosk_pin.locked_until":    "",
+		}})
+	}
+
+	if user.Status == "deactivated" || user.Status == "invited" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account is not active")
+		return
+	}
+
+	kioskPunch(c, kiosk, user, req.Action, "", nil)
+}
+
+func Set_kiosk_pin(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.SetKioskPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if !kioskPINPattern.MatchString(req.PIN) {
+		utils.ValidationErrorResponse(c, "Invalid PIN", []utils.FieldError{{Field: "pin", Message: "must be 4 to 8 digits"}})
+		return
+	}
+	if user.EmployeeCode == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You need an employee code to use a kiosk, ask an admin")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.PIN), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash PIN")
+		return
+	}
+
+	_, err = config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"kiosk_pin": models.KioskPIN{Hash: string(hash)},
+	}})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to set PIN")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Kiosk PIN set"})
+}
+
+func Reset_kiosk_pin(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	_, err := config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": target.ID}, bson.M{"$unset": bson.M{"kiosk_pin": ""}})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset PIN")
+		return
+	}
+
+	auditUserChange(user, "user.kiosk_pin_reset", target.ID, nil)
+
+	utils.SuccessResponse(c, gin.H{"message": "Kiosk PIN reset"})
+}
+
+// ------- the punch happens at the kiosk, so it gets the kiosk's location
+func kioskPunch(c *gin.Context, kiosk models.Kiosk, user models.User, action string, deviceID string, flags []models.AttendanceFlag) {
+	if kiosk.Site != "" && user.Site != kiosk.Site {
+		utils.ErrorResponse(c, http.StatusForbidden, "This kiosk is only for "+kiosk.Site)
+		return
+	}
+
+	p := punch{
+		Kind:     action,
+		At:       time.Now(),
+		Location: kiosk.Location,
+		DeviceID: deviceID,
+		IP:       c.ClientIP(),
+		KioskID:  &kiosk.ID,
+		Flags:    flags,
+	}
+
+	if _, err := recordPunch(context.TODO(), user, p); err != nil {
+		punchErrorResponse(c, err, "Failed to record attendance")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message": "Attendance recorded at " + kiosk.Name,
+		"name":    user.Name,
+		"action":  action,
+		"at":      p.At,
+	})
+}
+
+func validPunchAction(c *gin.Context, action string) bool {
+	if action != "check_in" && action != "check_out" {
+		utils.ValidationErrorResponse(c, "Invalid punch", []utils.FieldError{{Field: "action", Message: "must be check_in or check_out"}})
+		return false
+	}
+	return true
+}
+
+// ------- $inc so parallel wrong PINs all count, the one reaching the limit sets the lock
+func recordKioskPINFailure(ctx context.Context, user models.User) {
+	users := config.DB.Collection("users")
+
+	var updated models.User
+	err := users.FindOneAndUpdate(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$inc": bson.M{"kiosk_pin.failed_attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		log.Printf("Failed to count kiosk PIN failure of %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if updated.KioskPIN.FailedAttempts < maxKioskPINFailures {
+		return
+	}
+
+	_, err = users.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "kiosk_pin.failed_attempts": bson.M{"$gte": maxKioskPINFailures}},
+		bson.M{"$set": bson.M{"kiosk_pin.failed_attempts": 0, "kiosk_pin.locked_until": time.Now().Add(kioskPINLockout)}},
+	)
+	if err != nil {
+		log.Printf("Failed to lock kiosk PIN of %s: %v", user.ID.Hex(), err)
+	}
+}
+
+// ------- counts failures per kiosk in a fixed window so one kiosk can't try PINs across many employee codes
+func recordKioskFailure(ctx context.Context, kiosk models.Kiosk, window time.Duration) {
+	kiosks := config.DB.Collection("kiosks")
+	now := time.Now()
+
+	result, err := kiosks.UpdateOne(ctx,
+		bson.M{"_id": kiosk.ID, "pin_failures_since": bson.M{"$gt": now.Add(-window)}},
+		bson.M{"$inc": bson.M{"pin_failures": 1}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		_, err = kiosks.UpdateOne(ctx,
+			bson.M{"_id": kiosk.ID, "$or": bson.A{
+				bson.M{"pin_failures_since": nil},
+				bson.M{"pin_failures_since": bson.M{"$lte": now.Add(-window)}},
+			}},
+			bson.M{"$set": bson.M{"pin_failures": 1, "pin_failures_since": &now}},
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to count PIN failure on kiosk %s: %v", kiosk.ID.Hex(), err)
+	}
+}
+
+func newKioskToken() string {
+	return "kiosk_" + rand.Text()
+}
+
+func auditKioskChange(actor models.User, action string, kiosk models.Kiosk) {
+	details := map[string]any{"name": kiosk.Name, "site": kiosk.Site}
+	if err := recordAudit(context.TODO(), actor, action, "kiosk", kiosk.ID, details); err != nil {
+		logAuditFailure(action, kiosk.ID, err)
+	}
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadKiosk(c *gin.Context) (models.Kiosk, bool) {
+	var kiosk models.Kiosk
+
+	kioskID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid kiosk ID")
+		return kiosk, false
+	}
+
+	err = config.DB.Collection("kiosks").FindOne(context.TODO(), bson.M{"_id": kioskID}).Decode(&kiosk)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Kiosk not found")
+		return kiosk, false
+	}
+
+	return kiosk, true
+}
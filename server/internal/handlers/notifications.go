@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Get_notifications(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"user_id": user.ID},
+		bson.M{"user_id": nil, "role": user.Role},
+	}}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(100)
+	cursor, err := config.DB.Collection("notifications").Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	notifications := []models.Notification{}
+	if err = cursor.All(context.TODO(), &notifications); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode notifications")
+		return
+	}
+
+	utils.SuccessResponse(c, notifications)
+}
+
+// ------- per reviewer counts of reminders and escalations, ?from=2006-01-02&to=2006-01-02
+func Get_sla_breaches(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	createdAt := bson.M{}
+	if from := c.Query("from"); from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from date")
+			return
+		}
+		createdAt["$gte"] = day
+	}
+	if to := c.Query("to"); to != "" {
+		day, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to date")
+			return
+		}
+		createdAt["$lt"] = day.AddDate(0, 0, 1)
+	}
+
+	match := bson.M{}
+	if len(createdAt) > 0 {
+		match["created_at"] = createdAt
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{
+			"_id":               bson.M{"reviewer_id": "$reviewer_id", "reviewer_role": "$reviewer_role"},
+			"reminders":         bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$kind", "reminder"}}, 1, 0}}},
+			"escalations":       bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$kind", "escalation"}}, 1, 0}}},
+			"avg_waiting_hours": bson.M{"$avg": "$waiting_hours"},
+			"last_breach_at":    bson.M{"$max": "$created_at"},
+		}},
+		bson.M{"$lookup": bson.M{
+			"from":         "users",
+			"localField":   "_id.reviewer_id",
+			"foreignField": "_id",
+			"as":           "reviewer",
+		}},
+		bson.M{"$project": bson.M{
+			"_id":               0,
+			"reviewer_id":       "$_id.reviewer_id",
+			"reviewer_role":     "$_id.reviewer_role",
+			"reviewer_name":     bson.M{"$first": "$reviewer.name"},
+			"reminders":         1,
+			"escalations":       1,
+			"avg_waiting_hours": 1,
+			"last_breach_at":    1,
+		}},
+		bson.M{"$sort": bson.M{"escalations": -1, "reminders": -1}},
+	}
+
+	cursor, err := config.DB.Collection("sla_breaches").Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch SLA breaches")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	metrics := []bson.M{}
+	if err = cursor.All(context.TODO(), &metrics); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode SLA breaches")
+		return
+	}
+
+	utils.SuccessResponse(c, metrics)
+}
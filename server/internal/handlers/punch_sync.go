@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxSyncBatch = 100
+
+// ------- punches the app queued offline, recorded at their own timestamps. they are applied oldest first
+// whatever order they come in, results are in request order. a punch is refused when its timestamp is ahead
+// of the server by more than OFFLINE_MAX_SKEW (default 5m), and flagged when the device clock was off by more
+// than that (sent_at), when it arrives more than OFFLINE_MAX_DELAY (default 72h) late, or when something
+// later was already recorded. resending a client_id returns the first result
+func Sync_punches(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.PunchSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if len(req.Punches) == 0 || len(req.Punches) > maxSyncBatch {
+		utils.ValidationErrorResponse(c, "Invalid batch", []utils.FieldError{{Field: "punches", Message: "must have 1 to " + strconv.Itoa(maxSyncBatch) + " punches"}})
+		return
+	}
+
+	now := time.Now()
+	maxSkew := config.DurationFromEnv("OFFLINE_MAX_SKEW", 5*time.Minute)
+	maxDelay := config.DurationFromEnv("OFFLINE_MAX_DELAY", 72*time.Hour)
+
+	clockOffset := time.Duration(0)
+	if req.SentAt != nil {
+		clockOffset = now.Sub(*req.SentAt)
+	}
+
+	latest, err := latestPunchTime(context.TODO(), user)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load attendance")
+		return
+	}
+
+	order := make([]int, len(req.Punches))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return req.Punches[order[a]].Timestamp.Before(req.Punches[order[b]].Timestamp)
+	})
+
+	results := make([]models.PunchSyncResult, len(req.Punches))
+	seen := map[string]bool{}
+	for _, i := range order {
+		op := req.Punches[i]
+		result := models.PunchSyncResult{ClientID: op.ClientID, Status: "rejected"}
+
+		switch {
+		case op.ClientID == "" || len(op.ClientID) > 100:
+			result.Error = "client_id must be 1 to 100 characters"
+		case op.Action != "check_in" && op.Action != "check_out":
+			result.Error = "action must be check_in or check_out"
+		case op.Timestamp.IsZero():
+			result.Error = "timestamp is required"
+		case op.Timestamp.After(now.Add(maxSkew)):
+			result.Error = "timestamp is in the future"
+		case seen[op.ClientID]:
+			result.Status = "duplicate"
+		default:
+			result = syncPunch(c, user, op, now, clockOffset, maxSkew, maxDelay, latest)
+		}
+
+		seen[op.ClientID] = true
+		results[i] = result
+	}
+
+	utils.SuccessResponse(c, gin.H{"results": results})
+}
+
+func syncPunch(c *gin.Context, user models.User, op models.OfflinePunch, now time.Time, clockOffset time.Duration, maxSkew time.Duration, maxDelay time.Duration, latest *time.Time) models.PunchSyncResult {
+	result := models.PunchSyncResult{ClientID: op.ClientID, Status: "rejected"}
+
+	var synced models.SyncedPunch
+	err := config.DB.Collection("synced_punches").FindOne(context.TODO(), bson.M{"user_id": user.ID, "client_id": op.ClientID}).Decode(&synced)
+	if err == nil {
+		synced.Result.Status = "duplicate"
+		return synced.Result
+	}
+	if err != mongo.ErrNoDocuments {
+		result.Error = "Failed to check earlier syncs, send it again"
+		return result
+	}
+
+	flags, blocked, err := deviceFlags(context.TODO(), user, op.DeviceID, op.Action)
+	if err != nil {
+		result.Error = "Failed to check device, send it again"
+		return result
+	}
+	if blocked != "" {
+		result.Error = blocked
+		return result
+	}
+
+	flag := func(kind string, detail string) {
+		flags = append(flags, models.AttendanceFlag{Type: kind, Punch: op.Action, Detail: detail, CreatedAt: now})
+	}
+	if clockOffset.Abs() > maxSkew {
+		flag(models.FlagClockSkew, "device clock was "+clockOffset.Round(time.Second).Abs().String()+" off when the batch was sent")
+	}
+	if now.Sub(op.Timestamp) > maxDelay {
+		flag(models.FlagLateSync, "synced "+now.Sub(op.Timestamp).Round(time.Minute).String()+" after the punch")
+	}
+	if latest != nil && op.Timestamp.Before(*latest) {
+		flag(models.FlagOutOfOrder, "a later punch at "+latest.Format(time.RFC3339)+" was already recorded")
+	}
+
+	// ------- the sync may come from anywhere once the app is back online, so the site's networks only
+	// flag it for review. a punch timed within the skew of now is really a live punch and "reject" applies
+	mismatch, reject, err := networkMismatch(context.TODO(), user, c.ClientIP(), op.Action)
+	if err != nil {
+		result.Error = "Failed to load site, send it again"
+		return result
+	}
+	if mismatch != nil {
+		if reject && now.Sub(op.Timestamp).Abs() <= maxSkew {
+			result.Error = "Attendance for " + user.Site + " can only be recorded from the office network"
+			return result
+		}
+		flags = append(flags, *mismatch)
+	}
+
+	at := op.Timestamp.In(time.Local)
+	recorded, err := recordPunch(context.TODO(), user, punch{
+		Kind:     op.Action,
+		At:       at,
+		Location: op.Location,
+		DeviceID: op.DeviceID,
+		IP:       c.ClientIP(),
+		Flags:    flags,
+	})
+	if err != nil {
+		status, message := punchError(err, "Failed to record punch, send it again")
+		result.Error = message
+		// ------- only settled outcomes are kept, a server error can be retried with the same client_id
+		if status == http.StatusInternalServerError {
+			return result
+		}
+		return storeSyncedPunch(user, op, result)
+	}
+
+	result.Status = "applied"
+	result.Date = at.Format("2006-01-02")
+	result.Flags = recorded
+	return storeSyncedPunch(user, op, result)
+}
+
+// ------- a batch resent while the first was still running stores second and loses on the unique
+// (user_id, client_id) index, it answers with what the first one stored
+func storeSyncedPunch(user models.User, op models.OfflinePunch, result models.PunchSyncResult) models.PunchSyncResult {
+	synced := config.DB.Collection("synced_punches")
+	_, err := synced.InsertOne(context.TODO(), models.SyncedPunch{
+		UserID:    user.ID,
+		ClientID:  op.ClientID,
+		Action:    op.Action,
+		Timestamp: op.Timestamp,
+		Result:    result,
+		CreatedAt: time.Now(),
+	})
+	if err == nil {
+		return result
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		var first models.SyncedPunch
+		if err := synced.FindOne(context.TODO(), bson.M{"user_id": user.ID, "client_id": op.ClientID}).Decode(&first); err == nil {
+			first.Result.Status = "duplicate"
+			return first.Result
+		}
+	}
+
+	log.Printf("Failed to store synced punch %s of %s: %v", op.ClientID, user.ID.Hex(), err)
+	return result
+}
+
+// ------- newest check-in or check-out already on record, nil without any
+func latestPunchTime(ctx context.Context, user models.User) (*time.Time, error) {
+	var record models.Attendance
+	opts := options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}})
+	err := config.DB.Collection("attendance").FindOne(ctx, bson.M{"user_id": user.ID, "check_in": bson.M{"$ne": nil}}, opts).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if record.CheckOut != nil {
+		return record.CheckOut, nil
+	}
+	return record.CheckIn, nil
+}
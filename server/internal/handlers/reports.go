@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// employee fields team attendance and exports can be filtered on, straight query param -> users field
+var employeeFilterFields = []string{"department", "site", "employment_type", "job_title", "employee_code", "group"}
+
+// ------- totals per ?group_by= (any of employeeFilterFields or attr.<key>), same filters as /team-attendance
+func Get_team_attendance_summary(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	field, ok := groupByField(c)
+	if !ok {
+		return
+	}
+	if field == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "group_by is required")
+		return
+	}
+
+	filter, ok := teamAttendanceFilter(c)
+	if !ok {
+		return
+	}
+
+	groups, err := attendanceGroups(context.TODO(), filter, field)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to summarize attendance")
+		return
+	}
+
+	utils.SuccessResponse(c, groups)
+}
+
+// ------- CSV of team attendance with the employee profile and custom attributes next to every row, same filters as /team-attendance.
+// with ?group_by= it exports the summary instead
+func Export_team_attendance(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	field, ok := groupByField(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := teamAttendanceFilter(c)
+	if !ok {
+		return
+	}
+
+	if field != "" {
+		exportAttendanceGroups(c, filter, field)
+		return
+	}
+
+	definitions, err := loadAttributeDefinitions(context.TODO())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
+	cursor, err := config.DB.Collection("attendance").Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attendance")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	var attendances []models.Attendance
+	if err = cursor.All(context.TODO(), &attendances); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode attendance")
+		return
+	}
+
+	employees, err := usersByID(attendances)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch employees")
+		return
+	}
+
+	w := csvAttachment(c, "attendance")
+
+	header := []string{
+		"date", "employee_code", "name", "email", "department", "job_title", "site", "employment_type",
+		"check_in", "check_out", "total_hours", "status",
+	}
+	for _, definition := range definitions {
+		header = append(header, "attr."+definition.Key)
+	}
+	w.Write(header)
+
+	for _, attendance := range attendances {
+		employee := employees[attendance.UserID]
+		record := []string{
+			attendance.Date,
+			csvCell(employee.EmployeeCode),
+			csvCell(employee.Name),
+			csvCell(employee.Email),
+			csvCell(employee.Department),
+			csvCell(employee.JobTitle),
+			csvCell(employee.Site),
+			csvCell(employee.EmploymentType),
+			formatTime(attendance.CheckIn),
+			formatTime(attendance.CheckOut),
+			strconv.FormatFloat(attendance.TotalHours, 'f', 2, 64),
+			attendance.Status,
+		}
+		for _, definition := range definitions {
+			record = append(record, csvCell(formatAttribute(employee.Attributes[definition.Key])))
+		}
+		w.Write(record)
+	}
+
+	w.Flush()
+}
+
+func exportAttendanceGroups(c *gin.Context, filter bson.M, field string) {
+	groups, err := attendanceGroups(context.TODO(), filter, field)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to summarize attendance")
+		return
+	}
+
+	w := csvAttachment(c, "attendance-summary")
+	w.Write([]string{c.Query("group_by"), "employees", "days", "total_hours", "average_hours"})
+
+	for _, group := range groups {
+		w.Write([]string{
+			csvCell(formatAttribute(group.Key)),
+			strconv.Itoa(group.Employees),
+			strconv.Itoa(group.Days),
+			strconv.FormatFloat(group.TotalHours, 'f', 2, 64),
+			strconv.FormatFloat(group.AverageHours, 'f', 2, 64),
+		})
+	}
+
+	w.Flush()
+}
+
+func csvAttachment(c *gin.Context, name string) *csv.Writer {
+	filename := fmt.Sprintf("%s-%s.csv", name, time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	return csv.NewWriter(c.Writer)
+}
+
+// ------- values from users, imports and directories are escaped so a spreadsheet doesn't run =, +, -
+// or @ at the start of a cell as a formula. plain numbers are left alone
+func csvCell(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+// attendance joined to its employee and grouped by a users field path like "department" or "attributes.cost_center"
+func attendanceGroups(ctx context.Context, filter bson.M, field string) ([]models.AttendanceGroup, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "users",
+			"localField":   "user_id",
+			"foreignField": "_id",
+			"as":           "employee",
+		}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$employee", "preserveNullAndEmptyArrays": true}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         "$employee." + field,
+			"employees":   bson.M{"$addToSet": "$user_id"},
+			"days":        bson.M{"$sum": 1},
+			"total_hours": bson.M{"$sum": "$total_hours"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":           0,
+			"key":           "$_id",
+			"employees":     bson.M{"$size": "$employees"},
+			"days":          1,
+			"total_hours":   1,
+			"average_hours": bson.M{"$divide": bson.A{"$total_hours", "$days"}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"key": 1}}},
+	}
+
+	cursor, err := config.DB.Collection("attendance").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []models.AttendanceGroup{}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ------- ?group_by= as a users field path, empty when not asked for.
+// writes the error response itself, callers just return when ok is false
+func groupByField(c *gin.Context) (string, bool) {
+	groupBy := c.Query("group_by")
+	if groupBy == "" || slices.Contains(employeeFilterFields, groupBy) {
+		return groupBy, true
+	}
+
+	if key, ok := strings.CutPrefix(groupBy, "attr."); ok {
+		count, err := config.DB.Collection("attribute_definitions").CountDocuments(context.TODO(), bson.M{"key": key})
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+			return "", false
+		}
+		if count > 0 {
+			return "attributes." + key, true
+		}
+	}
+
+	utils.ErrorResponse(c, http.StatusBadRequest, "Invalid group_by, use one of "+strings.Join(employeeFilterFields, ", ")+" or attr.<key>")
+	return "", false
+}
+
+// ------- attr.<key>=value query params as a users filter, values are converted to the attribute's type.
+// writes the error response itself, callers just return when ok is false
+func attributeQueryFilter(c *gin.Context) (bson.M, bool) {
+	filter := bson.M{}
+
+	params := map[string]string{}
+	for param, values := range c.Request.URL.Query() {
+		if key, ok := strings.CutPrefix(param, "attr."); ok && len(values) > 0 && values[0] != "" {
+			params[key] = values[0]
+		}
+	}
+	if len(params) == 0 {
+		return filter, true
+	}
+
+	definitions, err := loadAttributeDefinitions(context.TODO())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+		return nil, false
+	}
+
+	for key, raw := range params {
+		index := slices.IndexFunc(definitions, func(d models.AttributeDefinition) bool { return d.Key == key })
+		if index < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Unknown attribute "+key)
+			return nil, false
+		}
+
+		value, message := attributeValue(definitions[index], raw)
+		if message != "" {
+			utils.ErrorResponse(c, http.StatusBadRequest, "attr."+key+" "+message)
+			return nil, false
+		}
+		filter["attributes."+key] = value
+	}
+
+	return filter, true
+}
+
+// ------- ?from=&to=&status=&user_id=&manager_id= plus any of employeeFilterFields and attr.<key>.
+// writes the error response itself, callers just return when ok is false
+func teamAttendanceFilter(c *gin.Context) (bson.M, bool) {
+	filter := bson.M{}
+
+	date := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lte"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid "+param+" date")
+			return nil, false
+		}
+		date[op] = value
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	// ------- ?flagged=true for records with any flag, ?flag=<type> for one kind
+	if c.Query("flagged") == "true" {
+		filter["flags.0"] = bson.M{"$exists": true}
+	}
+	if flag := c.Query("flag"); flag != "" {
+		filter["flags.type"] = flag
+	}
+
+	userFilter := bson.M{}
+	for _, key := range employeeFilterFields {
+		if value := c.Query(key); value != "" {
+			userFilter[key] = value
+		}
+	}
+
+	attributeFilter, ok := attributeQueryFilter(c)
+	if !ok {
+		return nil, false
+	}
+	maps.Copy(userFilter, attributeFilter)
+
+	for _, param := range []string{"user_id", "manager_id"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid "+param)
+			return nil, false
+		}
+		if param == "user_id" {
+			filter["user_id"] = id
+		} else {
+			userFilter["manager_id"] = id
+		}
+	}
+
+	if len(userFilter) == 0 {
+		return filter, true
+	}
+
+	ids, err := config.DB.Collection("users").Distinct(context.TODO(), "_id", userFilter)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to filter employees")
+		return nil, false
+	}
+
+	if userID, ok := filter["user_id"]; ok {
+		filter["user_id"] = bson.M{"$in": ids, "$eq": userID}
+	} else {
+		filter["user_id"] = bson.M{"$in": ids}
+	}
+
+	return filter, true
+}
+
+func usersByID(attendances []models.Attendance) (map[primitive.ObjectID]models.User, error) {
+	seen := map[primitive.ObjectID]bool{}
+	var ids bson.A
+	for _, attendance := range attendances {
+		if !seen[attendance.UserID] {
+			seen[attendance.UserID] = true
+			ids = append(ids, attendance.UserID)
+		}
+	}
+
+	users := map[primitive.ObjectID]models.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	cursor, err := config.DB.Collection("users").Find(context.TODO(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var list []models.User
+	if err = cursor.All(context.TODO(), &list); err != nil {
+		return nil, err
+	}
+	for _, u := range list {
+		users[u.ID] = u
+	}
+
+	return users, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatAttribute(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
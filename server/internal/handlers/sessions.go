@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ------- active sessions of the signed in user, the one making the request is marked current
+func Get_my_sessions(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	sessions, err := activeSessions(context.TODO(), user.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+
+	markCurrentSession(c, sessions)
+	utils.SuccessResponse(c, sessions)
+}
+
+func Revoke_my_session(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	revokeSession(c, user, user.ID, c.Param("id"))
+}
+
+// ------- sign out everywhere else, the current session stays
+func Revoke_my_other_sessions(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	filter := bson.M{}
+	if current, ok := c.Get("session"); ok {
+		filter["_id"] = bson.M{"$ne": current.(models.Session).ID}
+	}
+
+	count, err := revokeSessions(context.TODO(), user, user.ID, filter)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"revoked": count})
+}
+
+func Get_user_sessions(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := activeSessions(context.TODO(), target.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+
+	markCurrentSession(c, sessions)
+	utils.SuccessResponse(c, sessions)
+}
+
+func Revoke_user_session(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	revokeSession(c, user, target.ID, c.Param("session_id"))
+}
+
+func Revoke_user_sessions(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	count, err := revokeSessions(context.TODO(), user, target.ID, bson.M{})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"revoked": count})
+}
+
+// ------- stored before the token is signed so every token has a session to check against
+func createSession(c *gin.Context, user models.User, method string, expiresAt time.Time) (models.Session, error) {
+	now := time.Now()
+	userAgent := c.Request.UserAgent()
+
+	session := models.Session{
+		UserID:     user.ID,
+		Method:     method,
+		Device:     deviceLabel(userAgent),
+		UserAgent:  userAgent,
+		IP:         c.ClientIP(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		LastSeenIP: c.ClientIP(),
+		ExpiresAt:  expiresAt,
+	}
+
+	result, err := config.DB.Collection("sessions").InsertOne(context.TODO(), session)
+	if err != nil {
+		return session, err
+	}
+	session.ID = result.InsertedID.(primitive.ObjectID)
+
+	return session, nil
+}
+
+func activeSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+	cursor, err := config.DB.Collection("sessions").Find(ctx, bson.M{
+		"user_id":    userID,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func markCurrentSession(c *gin.Context, sessions []models.Session) {
+	current, ok := c.Get("session")
+	if !ok {
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current.(models.Session).ID
+	}
+}
+
+func revokeSession(c *gin.Context, actor models.User, userID primitive.ObjectID, sessionHex string) {
+	sessionID, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	count, err := revokeSessions(context.TODO(), actor, userID, bson.M{"_id": sessionID})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	if count == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Session revoked"})
+}
+
+// ------- filter narrows down the user's open sessions. audited when it isn't the user signing themselves out
+func revokeSessions(ctx context.Context, actor models.User, userID primitive.ObjectID, filter bson.M) (int64, error) {
+	filter["user_id"] = userID
+	filter["revoked_at"] = nil
+
+	now := time.Now()
+	result, err := config.DB.Collection("sessions").UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"revoked_at": &now,
+		"revoked_by": actor.ID,
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	if result.ModifiedCount > 0 && actor.ID != userID {
+		auditUserChange(actor, "user.sessions_revoked", userID, map[string]any{"count": result.ModifiedCount})
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// ------- "Chrome on Windows" style label, the raw user agent is kept next to it
+func deviceLabel(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"okhttp", "Android app"},
+		{"cfnetwork", "iOS app"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, platform := range []struct{ token, name string }{
+		{"android", "Android"},
+		{"iphone", "iOS"},
+		{"ipad", "iPadOS"},
+		{"windows", "Windows"},
+		{"mac os", "macOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, platform.token) {
+			return browser + " on " + platform.name
+		}
+	}
+	return browser
+}
+
+// ------- how the user proved who they are, kept on the session
+func loginMethod(user models.User) string {
+	if user.AuthProvider == "ldap" {
+		return "ldap"
+	}
+	return "password"
+}
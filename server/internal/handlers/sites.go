@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Get_sites(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := config.DB.Collection("sites").Find(context.TODO(), bson.M{}, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch sites")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	sites := []models.Site{}
+	if err = cursor.All(context.TODO(), &sites); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode sites")
+		return
+	}
+
+	utils.SuccessResponse(c, sites)
+}
+
+func Create_site(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req models.SiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors, err := validateSite(context.TODO(), &req, primitive.NilObjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check site name")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid site", fieldErrors)
+		return
+	}
+
+	now := time.Now()
+	site := models.Site{
+		Name:            req.Name,
+		AllowedNetworks: req.AllowedNetworks,
+		NetworkPolicy:   req.NetworkPolicy,
+		NetworkRoles:    req.NetworkRoles,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	result, err := config.DB.Collection("sites").InsertOne(context.TODO(), site)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create site")
+		return
+	}
+	site.ID = result.InsertedID.(primitive.ObjectID)
+
+	auditSiteChange(user, "site.created", site)
+
+	utils.SuccessResponse(c, site)
+}
+
+func Update_site(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	site, ok := loadSite(c)
+	if !ok {
+		return
+	}
+
+	var req models.SiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors, err := validateSite(context.TODO(), &req, site.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check site name")
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid site", fieldErrors)
+		return
+	}
+
+	// ------- users point at the site by name, so a rename carries them along
+	site.UpdatedAt = time.Now()
+	err = config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		if req.Name != site.Name {
+			_, err := config.DB.Collection("users").UpdateMany(sc, bson.M{"site": site.Name}, bson.M{"$set": bson.M{"site": req.Name}})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := config.DB.Collection("sites").UpdateOne(sc, bson.M{"_id": site.ID}, bson.M{"$set": bson.M{
+			"name":             req.Name,
+			"allowed_networks": req.AllowedNetworks,
+			"network_policy":   req.NetworkPolicy,
+			"network_roles":    req.NetworkRoles,
+			"updated_at":       site.UpdatedAt,
+		}})
+		return err
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update site")
+		return
+	}
+
+	site.Name = req.Name
+	site.AllowedNetworks = req.AllowedNetworks
+	site.NetworkPolicy = req.NetworkPolicy
+	site.NetworkRoles = req.NetworkRoles
+
+	auditSiteChange(user, "site.updated", site)
+
+	utils.SuccessResponse(c, site)
+}
+
+// ------- users keep their site name, it just stops having a network policy
+func Delete_site(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	site, ok := loadSite(c)
+	if !ok {
+		return
+	}
+
+	if _, err := config.DB.Collection("sites").DeleteOne(context.TODO(), bson.M{"_id": site.ID}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete site")
+		return
+	}
+
+	auditSiteChange(user, "site.deleted", site)
+
+	utils.SuccessResponse(c, gin.H{"message": "Site deleted successfully"})
+}
+
+// ------- punches of a site with allowed_networks must come from one of them. with network_policy "reject"
+// anything else is refused, with "flag" it is recorded with a network_mismatch flag.
+// writes the error response itself when ok is false
+func checkNetwork(c *gin.Context, user models.User, punch string) ([]models.AttendanceFlag, bool) {
+	// ------- ClientIP only believes X-Forwarded-For from TRUSTED_PROXIES
+	mismatch, reject, err := networkMismatch(context.TODO(), user, c.ClientIP(), punch)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load site")
+		return nil, false
+	}
+	if mismatch == nil {
+		return nil, true
+	}
+
+	if reject {
+		utils.ErrorResponse(c, http.StatusForbidden, "Attendance for "+user.Site+" can only be recorded from the office network")
+		return nil, false
+	}
+	return []models.AttendanceFlag{*mismatch}, true
+}
+
+// ------- nil when clientIP is fine for the user's site, otherwise the network_mismatch flag and whether
+// the site's policy is reject
+func networkMismatch(ctx context.Context, user models.User, clientIP string, punch string) (*models.AttendanceFlag, bool, error) {
+	if user.Site == "" {
+		return nil, false, nil
+	}
+
+	var site models.Site
+	err := config.DB.Collection("sites").FindOne(ctx, bson.M{"name": user.Site}).Decode(&site)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(site.AllowedNetworks) == 0 || (len(site.NetworkRoles) > 0 && !slices.Contains(site.NetworkRoles, user.Role)) {
+		return nil, false, nil
+	}
+	if utils.IPInNetworks(clientIP, site.AllowedNetworks) {
+		return nil, false, nil
+	}
+
+	return &models.AttendanceFlag{
+		Type:      models.FlagNetworkMismatch,
+		Punch:     punch,
+		Detail:    clientIP + " is outside the " + site.Name + " networks",
+		CreatedAt: time.Now(),
+	}, site.NetworkPolicy == "reject", nil
+}
+
+func validateSite(ctx context.Context, req *models.SiteRequest, except primitive.ObjectID) ([]utils.FieldError, error) {
+	var errs []utils.FieldError
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		errs = append(errs, utils.FieldError{Field: "name", Message: "is required"})
+	} else {
+		count, err := config.DB.Collection("sites").CountDocuments(ctx, bson.M{"_id": bson.M{"$ne": except}, "name": req.Name})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			errs = append(errs, utils.FieldError{Field: "name", Message: "is already used by another site"})
+		}
+	}
+
+	if req.AllowedNetworks == nil {
+		req.AllowedNetworks = []string{}
+	}
+	for i, cidr := range req.AllowedNetworks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = append(errs, utils.FieldError{Field: "allowed_networks[" + strconv.Itoa(i) + "]", Message: "must be a CIDR like 203.0.113.0/24"})
+		}
+	}
+
+	if req.NetworkPolicy == "" {
+		req.NetworkPolicy = "reject"
+	}
+	if !slices.Contains(models.NetworkPolicies, req.NetworkPolicy) {
+		errs = append(errs, utils.FieldError{Field: "network_policy", Message: "must be one of " + strings.Join(models.NetworkPolicies, ", ")})
+	}
+
+	for i, role := range req.NetworkRoles {
+		if !slices.Contains(models.Roles, role) {
+			errs = append(errs, utils.FieldError{Field: "network_roles[" + strconv.Itoa(i) + "]", Message: "must be one of " + strings.Join(models.Roles, ", ")})
+		}
+	}
+
+	return errs, nil
+}
+
+func auditSiteChange(actor models.User, action string, site models.Site) {
+	details := map[string]any{"name": site.Name, "allowed_networks": site.AllowedNetworks, "network_policy": site.NetworkPolicy}
+	if err := recordAudit(context.TODO(), actor, action, "site", site.ID, details); err != nil {
+		logAuditFailure(action, site.ID, err)
+	}
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadSite(c *gin.Context) (models.Site, bool) {
+	var site models.Site
+
+	siteID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid site ID")
+		return site, false
+	}
+
+	err = config.DB.Collection("sites").FindOne(context.TODO(), bson.M{"_id": siteID}).Decode(&site)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Site not found")
+		return site, false
+	}
+
+	return site, true
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/sso"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+)
+
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	errSSONotProvisioned  = errors.New("no account for this identity")
+	errSSOEmailUnverified = errors.New("email not verified, can't link to an existing account")
+)
+
+// ------- starts the authorization-code flow, the browser is sent to the IdP
+func Oidc_login(c *gin.Context) {
+	provider, err := sso.OIDC(c.Request.Context())
+	if errors.Is(err, sso.ErrOIDCDisabled) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Single sign-on is not configured")
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC provider unavailable: %v", err)
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Identity provider is unavailable")
+		return
+	}
+
+	now := time.Now()
+	login := models.OIDCLogin{
+		State:     rand.Text(),
+		Nonce:     rand.Text(),
+		Verifier:  oauth2.GenerateVerifier(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(oidcLoginTTL),
+	}
+
+	if _, err := config.DB.Collection("oidc_logins").InsertOne(context.TODO(), login); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(login.State, login.Nonce, login.Verifier))
+}
+
+// ------- the IdP redirects here. the token goes back to the client in the URL fragment of
+// APP_BASE_URL/sso/callback so it never shows up in server or proxy logs, errors go as #error=
+func Oidc_callback(c *gin.Context) {
+	if idpError := c.Query("error"); idpError != "" {
+		ssoRedirect(c, url.Values{"error": {idpError}})
+		return
+	}
+
+	provider, err := sso.OIDC(c.Request.Context())
+	if err != nil {
+		ssoRedirect(c, url.Values{"error": {"sso_unavailable"}})
+		return
+	}
+
+	// ------- deleting it makes the state single use
+	var login models.OIDCLogin
+	err = config.DB.Collection("oidc_logins").FindOneAndDelete(
+		context.TODO(),
+		bson.M{"state": c.Query("state"), "expires_at": bson.M{"$gt": time.Now()}},
+	).Decode(&login)
+	if err != nil {
+		ssoRedirect(c, url.Values{"error": {"invalid_state"}})
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), login.Verifier)
+	if err != nil {
+		log.Printf("OIDC callback failed: %v", err)
+		ssoRedirect(c, url.Values{"error": {"invalid_response"}})
+		return
+	}
+	if identity.Nonce != login.Nonce {
+		ssoRedirect(c, url.Values{"error": {"invalid_nonce"}})
+		return
+	}
+
+	user, err := provisionExternalUser(context.TODO(), models.ExternalIdentity{
+		Provider:   "oidc",
+		ExternalID: identity.Subject,
+		Email:      identity.Email,
+		Name:       identity.Name,
+		Role:       identity.Role,
+
+		EmailVerified: identity.EmailVerified,
+		RoleManaged:   identity.RoleClaimed,
+	})
+	if errors.Is(err, errSSONotProvisioned) {
+		ssoRedirect(c, url.Values{"error": {"not_provisioned"}})
+		return
+	}
+	if errors.Is(err, errSSOEmailUnverified) {
+		ssoRedirect(c, url.Values{"error": {"email_not_verified"}})
+		return
+	}
+	if err != nil {
+		log.Printf("Provisioning %s failed: %v", identity.Email, err)
+		ssoRedirect(c, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	if user.Status == "deactivated" {
+		ssoRedirect(c, url.Values{"error": {"account_deactivated"}})
+		return
+	}
+
+	// ------- same second factor as the password login, an IdP account linked by email mustn't skip it
+	if user.TwoFactor.Enabled || twoFactorRequired(user) {
+		mfaToken, err := issueMFAToken(user, "oidc")
+		if err != nil {
+			ssoRedirect(c, url.Values{"error": {"server_error"}})
+			return
+		}
+
+		ssoRedirect(c, url.Values{
+			"mfa_token":      {mfaToken},
+			"setup_required": {strconv.FormatBool(!user.TwoFactor.Enabled)},
+		})
+		return
+	}
+
+	tokenString, err := issueToken(c, user, "oidc")
+	if err != nil {
+		ssoRedirect(c, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	ssoRedirect(c, url.Values{"token": {tokenString}})
+}
+
+// ------- finds the user by provider id, then by email (linking the account), otherwise creates them
+// unless SSO_AUTO_PROVISION=false. name, email and a mapped role follow the provider on every login
+func provisionExternalUser(ctx context.Context, identity models.ExternalIdentity) (models.User, error) {
+	var user models.User
+
+	if identity.Role != "" && !slices.Contains(models.Roles, identity.Role) {
+		log.Printf("Ignoring unknown role %q from %s for %s", identity.Role, identity.Provider, identity.Email)
+		identity.Role = ""
+	}
+	// ------- someone taken out of the IdP's admin group must not keep the admin role
+	demoted := identity.RoleManaged && identity.Role == ""
+	if demoted {
+		identity.Role = defaultSSORole()
+	}
+
+	users := config.DB.Collection("users")
+	match := bson.M{"auth_provider": identity.Provider, "external_id": identity.ExternalID}
+	if identity.DirectoryID != nil {
+		match["directory_id"] = *identity.DirectoryID
+	}
+
+	err := users.FindOne(ctx, match).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = users.FindOne(ctx, bson.M{"email": identity.Email}).Decode(&user)
+		// ------- whoever controls an unverified address at the IdP would get the account it belongs to
+		if err == nil && !identity.EmailVerified {
+			return user, errSSOEmailUnverified
+		}
+	}
+
+	if err == mongo.ErrNoDocuments {
+		if os.Getenv("SSO_AUTO_PROVISION") == "false" {
+			return user, errSSONotProvisioned
+		}
+		return createExternalUser(ctx, identity)
+	}
+	if err != nil {
+		return user, err
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"auth_provider": identity.Provider,
+		"external_id":   identity.ExternalID,
+		"email":         identity.Email,
+		"updated_at":    &now,
+	}
+	if identity.DirectoryID != nil {
+		set["directory_id"] = *identity.DirectoryID
+	}
+	if identity.Name != "" {
+		set["name"] = identity.Name
+	}
+	if identity.Role != "" && identity.Role != user.Role {
+		set["role"] = identity.Role
+	}
+
+	// ------- signing in through the IdP proves the address just like the invitation link would
+	if user.Status == "invited" {
+		set["status"] = "active"
+		if err := revokeOpenInvitations(ctx, user); err != nil {
+			return user, err
+		}
+	}
+
+	if _, err := users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set}); err != nil {
+		return user, err
+	}
+
+	if role, ok := set["role"]; ok {
+		details := map[string]any{"provider": identity.Provider, "from": user.Role, "to": role}
+		if demoted {
+			details["reason"] = "no mapped role"
+		}
+		auditUserChange(user, "user.role_synced", user.ID, details)
+	}
+	if user.AuthProvider != identity.Provider {
+		auditUserChange(user, "user.linked", user.ID, map[string]any{"provider": identity.Provider})
+	}
+
+	err = users.FindOne(ctx, bson.M{"_id": user.ID}).Decode(&user)
+	return user, err
+}
+
+func defaultSSORole() string {
+	if role := os.Getenv("SSO_DEFAULT_ROLE"); slices.Contains(models.Roles, role) {
+		return role
+	}
+	return models.RoleEmployee
+}
+
+func createExternalUser(ctx context.Context, identity models.ExternalIdentity) (models.User, error) {
+	role := identity.Role
+	if role == "" {
+		role = defaultSSORole()
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	user := models.User{
+		ID:           primitive.NewObjectID(),
+		Email:        identity.Email,
+		Name:         name,
+		Role:         role,
+		Status:       "active",
+		AuthProvider: identity.Provider,
+		ExternalID:   identity.ExternalID,
+		DirectoryID:  identity.DirectoryID,
+		CreatedAt:    time.Now(),
+	}
+
+	err := config.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := config.DB.Collection("users").InsertOne(sc, user); err != nil {
+			return err
+		}
+		return recordAudit(sc, user, "user.provisioned", "user", user.ID, map[string]any{"provider": identity.Provider, "role": role})
+	})
+
+	return user, err
+}
+
+func ssoRedirect(c *gin.Context, fragment url.Values) {
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3001"
+	}
+
+	c.Redirect(http.StatusFound, baseURL+"/sso/callback#"+fragment.Encode())
+}
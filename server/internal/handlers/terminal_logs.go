@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/terminal"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxTerminalLogBytes = 10 << 20
+
+// ------- punches of one employee on one day, as the terminal saw them
+type terminalDay struct {
+	user    models.User
+	code    string
+	date    string
+	punches []terminal.Punch
+}
+
+// ------- multipart "file" with a terminal log. ?terminal= names the terminal (required), ?timezone= is its clock
+// (default the server's), ?direction_column= the 1-based status column (default 4, 0 when there is none),
+// ?code_field= employee_code (default) or attr.<key> of a string attribute, ?dry_run=true only reports.
+// importing the same log again changes nothing, times that differ from what a record has are reported as conflicts
+func Ingest_terminal_log(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	terminalName := strings.TrimSpace(c.Query("terminal"))
+	if terminalName == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "terminal is required")
+		return
+	}
+
+	loc := time.Local
+	if tz := c.Query("timezone"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid timezone")
+			return
+		}
+	}
+
+	directionColumn, err := strconv.Atoi(c.DefaultQuery("direction_column", "4"))
+	if err != nil || directionColumn < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid direction_column")
+		return
+	}
+
+	codeField, ok := terminalCodeField(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTerminalLogBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Log file is required")
+		return
+	}
+	if header.Size > maxTerminalLogBytes {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Log file is too large")
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	punches, lineErrors, err := terminal.ParseAttlog(file, loc, directionColumn)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := models.TerminalIngestReport{
+		Terminal:  terminalName,
+		DryRun:    c.Query("dry_run") == "true",
+		Punches:   len(punches),
+		Invalid:   lineErrors,
+		Unmatched: []models.UnmatchedCode{},
+		Conflicts: []models.TerminalConflict{},
+		Skipped:   []models.SkippedDay{},
+	}
+	if report.Invalid == nil {
+		report.Invalid = []models.TerminalLineError{}
+	}
+
+	days, err := terminalDays(context.TODO(), punches, codeField, &report)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to match terminal codes")
+		return
+	}
+
+	for _, day := range days {
+		if err := mergeTerminalDay(context.TODO(), day, terminalName, &report); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to merge attendance for "+day.code+" on "+day.date)
+			return
+		}
+	}
+
+	if !report.DryRun {
+		details := map[string]any{
+			"terminal":  terminalName,
+			"punches":   report.Punches,
+			"created":   report.Created,
+			"updated":   report.Updated,
+			"conflicts": len(report.Conflicts),
+			"skipped":   len(report.Skipped),
+		}
+		if err := recordAudit(context.TODO(), user, "attendance.terminal_ingested", "attendance", primitive.NilObjectID, details); err != nil {
+			logAuditFailure("attendance.terminal_ingested", primitive.NilObjectID, err)
+		}
+	}
+
+	utils.SuccessResponse(c, report)
+}
+
+// ------- the user field terminal codes are matched against, writes the error response itself when ok is false
+func terminalCodeField(c *gin.Context) (string, bool) {
+	field := c.DefaultQuery("code_field", "employee_code")
+	if field == "employee_code" {
+		return field, true
+	}
+
+	key, found := strings.CutPrefix(field, "attr.")
+	if !found {
+		utils.ErrorResponse(c, http.StatusBadRequest, "code_field must be employee_code or attr.<key>")
+		return "", false
+	}
+
+	definitions, err := loadAttributeDefinitions(context.TODO())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+		return "", false
+	}
+	if !slices.ContainsFunc(definitions, func(def models.AttributeDefinition) bool { return def.Key == key && def.Type == "string" }) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "code_field must name a string attribute")
+		return "", false
+	}
+
+	return "attributes." + key, true
+}
+
+// ------- groups punches per employee and day in the terminal's timezone, unknown codes go to the report
+func terminalDays(ctx context.Context, punches []terminal.Punch, codeField string, report *models.TerminalIngestReport) ([]terminalDay, error) {
+	codes := []string{}
+	for _, p := range punches {
+		if !slices.Contains(codes, p.Code) {
+			codes = append(codes, p.Code)
+		}
+	}
+
+	cursor, err := config.DB.Collection("users").Find(ctx, bson.M{codeField: bson.M{"$in": codes}})
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	// ------- attributes aren't unique like employee codes, a code that several users share can't be
+	// attributed to any of them
+	byCode := map[string][]models.User{}
+	for _, u := range users {
+		code := u.EmployeeCode
+		if key, found := strings.CutPrefix(codeField, "attributes."); found {
+			code, _ = u.Attributes[key].(string)
+		}
+		byCode[code] = append(byCode[code], u)
+	}
+
+	unmatched := map[string]int{}
+	grouped := map[string]*terminalDay{}
+	var order []string
+	for _, p := range punches {
+		if len(byCode[p.Code]) != 1 {
+			unmatched[p.Code]++
+			continue
+		}
+		u := byCode[p.Code][0]
+
+		date := p.At.Format("2006-01-02")
+		key := p.Code + "|" + date
+		if grouped[key] == nil {
+			grouped[key] = &terminalDay{user: u, code: p.Code, date: date}
+			order = append(order, key)
+		}
+		grouped[key].punches = append(grouped[key].punches, p)
+	}
+
+	for code, count := range unmatched {
+		unmatchedCode := models.UnmatchedCode{Code: code, Punches: count}
+		if n := len(byCode[code]); n > 1 {
+			unmatchedCode.Reason = strconv.Itoa(n) + " users have this code"
+		}
+		report.Unmatched = append(report.Unmatched, unmatchedCode)
+	}
+	sort.Slice(report.Unmatched, func(i, j int) bool { return report.Unmatched[i].Code < report.Unmatched[j].Code })
+
+	sort.Strings(order)
+	days := make([]terminalDay, 0, len(order))
+	for _, key := range order {
+		days = append(days, *grouped[key])
+	}
+	return days, nil
+}
+
+// ------- a check-out is only recorded after a check-in, like the punch endpoints
+const outWithoutIn = "check-out without a check-in"
+
+func (day terminalDay) skip(report *models.TerminalIngestReport, reason string) {
+	report.Skipped = append(report.Skipped, models.SkippedDay{UserID: day.user.ID, Code: day.code, Date: day.date, Reason: reason})
+}
+
+// ------- fills missing check-in/check-out times, never overwrites one that differs. the same account and
+// employment date rules as a punch apply, days that break them are skipped and reported
+func mergeTerminalDay(ctx context.Context, day terminalDay, terminalName string, report *models.TerminalIngestReport) error {
+	if day.user.Status == "deactivated" || day.user.Status == "invited" {
+		day.skip(report, "account is "+day.user.Status)
+		return nil
+	}
+	if msg := employmentDateError(day.user, day.date); msg != "" {
+		day.skip(report, msg)
+		return nil
+	}
+
+	checkIn, checkOut := dayBounds(day.punches)
+	device := "terminal:" + terminalName
+
+	var existing models.Attendance
+	err := config.DB.Collection("attendance").FindOne(ctx, bson.M{"user_id": day.user.ID, "date": day.date}).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	if err == mongo.ErrNoDocuments {
+		if checkIn == nil {
+			day.skip(report, outWithoutIn)
+			return nil
+		}
+
+		now := time.Now()
+		attendance := models.Attendance{
+			UserID:    day.user.ID,
+			Date:      day.date,
+			CheckIn:   checkIn,
+			CheckOut:  checkOut,
+			Status:    "pending",
+			CreatedAt: now,
+			UpdatedAt: now,
+
+			CheckInDevice: device,
+		}
+		if checkOut != nil {
+			attendance.CheckOutDevice = device
+			attendance.TotalHours = checkOut.Sub(*checkIn).Hours()
+			attendance.Status = "valid"
+		}
+
+		if report.DryRun {
+			report.Created++
+			return nil
+		}
+		_, err = config.DB.Collection("attendance").InsertOne(ctx, attendance)
+		if !mongo.IsDuplicateKeyError(err) {
+			if err == nil {
+				report.Created++
+			}
+			return err
+		}
+
+		// ------- a punch created the record since the lookup, merge into that one instead
+		err = config.DB.Collection("attendance").FindOne(ctx, bson.M{"user_id": day.user.ID, "date": day.date}).Decode(&existing)
+		if err != nil {
+			return err
+		}
+	}
+
+	return mergeTerminalRecord(ctx, day, existing, checkIn, checkOut, device, report)
+}
+
+func mergeTerminalRecord(ctx context.Context, day terminalDay, existing models.Attendance, checkIn *time.Time, checkOut *time.Time, device string, report *models.TerminalIngestReport) error {
+	conflict := func(field string, existingAt time.Time, terminalAt time.Time) {
+		report.Conflicts = append(report.Conflicts, models.TerminalConflict{
+			UserID:   day.user.ID,
+			Code:     day.code,
+			Date:     day.date,
+			Field:    field,
+			Existing: existingAt,
+			Terminal: terminalAt,
+		})
+	}
+
+	set := bson.M{}
+	finalIn, finalOut := existing.CheckIn, existing.CheckOut
+
+	if checkIn != nil {
+		switch {
+		case existing.CheckIn == nil:
+			set["check_in"] = checkIn
+			set["check_in_device"] = device
+			finalIn = checkIn
+		case !sameSecond(*existing.CheckIn, *checkIn):
+			conflict("check_in", *existing.CheckIn, *checkIn)
+		}
+	}
+
+	if checkOut != nil {
+		switch {
+		case finalIn == nil:
+			day.skip(report, outWithoutIn)
+			return nil
+		case existing.CheckOut == nil && checkOut.After(*finalIn):
+			set["check_out"] = checkOut
+			set["check_out_device"] = device
+			finalOut = checkOut
+		case existing.CheckOut == nil:
+			conflict("check_out", *finalIn, *checkOut)
+		case existing.CheckOut != nil && !sameSecond(*existing.CheckOut, *checkOut):
+			conflict("check_out", *existing.CheckOut, *checkOut)
+		}
+	}
+
+	if len(set) == 0 {
+		report.Unchanged++
+		return nil
+	}
+
+	if finalIn != nil && finalOut != nil {
+		set["total_hours"] = finalOut.Sub(*finalIn).Hours()
+		set["status"] = "valid"
+	}
+	set["updated_at"] = time.Now()
+
+	report.Updated++
+	if report.DryRun {
+		return nil
+	}
+
+	// ------- only if the fields are still empty, a punch that arrived in between wins
+	filter := bson.M{"_id": existing.ID}
+	for _, field := range []string{"check_in", "check_out"} {
+		if _, ok := set[field]; ok {
+			filter[field] = nil
+		}
+	}
+	_, err := config.DB.Collection("attendance").UpdateOne(ctx, filter, bson.M{"$set": set})
+	return err
+}
+
+// ------- first "in" and last "out" of the day. terminals that don't record a direction give
+// the first punch as check-in and the last as check-out
+func dayBounds(punches []terminal.Punch) (*time.Time, *time.Time) {
+	sort.Slice(punches, func(i, j int) bool { return punches[i].At.Before(punches[j].At) })
+
+	var checkIn, checkOut *time.Time
+	for i := range punches {
+		switch punches[i].Direction {
+		case "in":
+			if checkIn == nil {
+				checkIn = &punches[i].At
+			}
+		case "out":
+			checkOut = &punches[i].At
+		}
+	}
+
+	if checkIn == nil {
+		for i := range punches {
+			if punches[i].Direction == "" {
+				checkIn = &punches[i].At
+				break
+			}
+		}
+	}
+	if checkOut == nil && len(punches) > 1 && punches[len(punches)-1].Direction == "" {
+		checkOut = &punches[len(punches)-1].At
+	}
+
+	if checkIn != nil && checkOut != nil && !checkOut.After(*checkIn) {
+		checkOut = nil
+	}
+	return checkIn, checkOut
+}
+
+// attendance times are stored with milliseconds, terminals only log seconds
+func sameSecond(a time.Time, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
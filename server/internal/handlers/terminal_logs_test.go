@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sourav01112/server/internal/terminal"
+)
+
+func TestDayBounds(t *testing.T) {
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	at := func(hour int, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	punch := func(hour int, minute int, direction string) terminal.Punch {
+		return terminal.Punch{Code: "42", At: at(hour, minute), Direction: direction}
+	}
+
+	tests := []struct {
+		name    string
+		punches []terminal.Punch
+		wantIn  *time.Time
+		wantOut *time.Time
+	}{
+		{
+			name:    "one in and one out",
+			punches: []terminal.Punch{punch(9, 0, "in"), punch(18, 0, "out")},
+			wantIn:  ptrTime(at(9, 0)),
+			wantOut: ptrTime(at(18, 0)),
+		},
+		{
+			name:    "first in and last out of several",
+			punches: []terminal.Punch{punch(9, 0, "in"), punch(13, 0, "out"), punch(14, 0, "in"), punch(18, 30, "out")},
+			wantIn:  ptrTime(at(9, 0)),
+			wantOut: ptrTime(at(18, 30)),
+		},
+		{
+			name:    "unsorted input",
+			punches: []terminal.Punch{punch(18, 0, "out"), punch(9, 5, "in"), punch(8, 55, "in")},
+			wantIn:  ptrTime(at(8, 55)),
+			wantOut: ptrTime(at(18, 0)),
+		},
+		{
+			name:    "no directions, first and last punch",
+			punches: []terminal.Punch{punch(12, 0, ""), punch(9, 0, ""), punch(17, 0, "")},
+			wantIn:  ptrTime(at(9, 0)),
+			wantOut: ptrTime(at(17, 0)),
+		},
+		{
+			name:    "single punch without direction is only a check-in",
+			punches: []terminal.Punch{punch(9, 0, "")},
+			wantIn:  ptrTime(at(9, 0)),
+		},
+		{
+			name:    "only an out",
+			punches: []terminal.Punch{punch(18, 0, "out")},
+			wantOut: ptrTime(at(18, 0)),
+		},
+		{
+			name:    "directed in, undirected last punch is the check-out",
+			punches: []terminal.Punch{punch(9, 0, "in"), punch(18, 0, "")},
+			wantIn:  ptrTime(at(9, 0)),
+			wantOut: ptrTime(at(18, 0)),
+		},
+		{
+			name:    "out before the in is dropped",
+			punches: []terminal.Punch{punch(7, 0, "out"), punch(9, 0, "in")},
+			wantIn:  ptrTime(at(9, 0)),
+		},
+		{
+			name:    "out at the same second as the in is dropped",
+			punches: []terminal.Punch{punch(9, 0, "in"), punch(9, 0, "out")},
+			wantIn:  ptrTime(at(9, 0)),
+		},
+		{
+			name: "no punches",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotIn, gotOut := dayBounds(tt.punches)
+			if !sameTime(gotIn, tt.wantIn) || !sameTime(gotOut, tt.wantOut) {
+				t.Errorf("dayBounds() = (%v, %v), want (%v, %v)", gotIn, gotOut, tt.wantIn, tt.wantOut)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
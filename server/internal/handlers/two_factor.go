@@ -0,0 +1,535 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/tokens"
+	"github.com/Sourav01112/server/internal/totp"
+	"github.com/Sourav01112/server/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	mfaTokenTTL            = 5 * time.Minute
+	mfaTokenPurpose        = "two_factor"
+	recoveryCodeCount      = 10
+	maxTwoFactorAttempts   = 5
+	twoFactorLockoutPeriod = 15 * time.Minute
+)
+
+var (
+	errTwoFactorInvalid = errors.New("invalid two factor code")
+	errTwoFactorLocked  = errors.New("two factor temporarily locked")
+)
+
+// ------- step two of login, with the mfa_token from step one. while setting up during login the
+// first valid code also enables 2FA and the response carries the recovery codes
+func Verify_two_factor_login(c *gin.Context) {
+	var req models.TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	user, method, ok := loadMFAUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	var recoveryCodes []string
+	var err error
+	switch {
+	case user.TwoFactor.Enabled && req.RecoveryCode != "":
+		err = useRecoveryCode(context.TODO(), user, req.RecoveryCode)
+	case user.TwoFactor.Enabled:
+		err = verifyTOTP(context.TODO(), user, user.TwoFactor.Secret, req.Code)
+	case user.TwoFactor.PendingSecret != "":
+		recoveryCodes, err = enableTwoFactor(context.TODO(), user, req.Code)
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor setup has not been started")
+		return
+	}
+	if err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	tokenString, err := issueToken(c, user, method)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	utils.SuccessResponse(c, models.LoginResponse{
+		Token:         tokenString,
+		User:          user,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// ------- users who must have 2FA but haven't enrolled yet get their secret here, before they have a session
+func Setup_two_factor_login(c *gin.Context) {
+	var req models.MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	user, _, ok := loadMFAUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	startTwoFactorSetup(c, user)
+}
+
+// ------- voluntary enrollment from a logged in session, confirmed with Confirm_two_factor
+func Enroll_two_factor(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	startTwoFactorSetup(c, user)
+}
+
+func Confirm_two_factor(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	if user.TwoFactor.Enabled {
+		utils.ErrorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if user.TwoFactor.PendingSecret == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor setup has not been started")
+		return
+	}
+
+	recoveryCodes, err := enableTwoFactor(context.TODO(), user, req.Code)
+	if err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// ------- needs the password and a current code, and is refused while 2FA is required for the user
+func Disable_two_factor(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Password and code are required")
+		return
+	}
+
+	if !user.TwoFactor.Enabled {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	if twoFactorRequired(user) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Two-factor authentication is required for your account")
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	if err := verifyTOTP(context.TODO(), user, user.TwoFactor.Secret, req.Code); err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	if err := clearTwoFactor(context.TODO(), user.ID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	auditUserChange(user, "user.two_factor_disabled", user.ID, nil)
+
+	utils.SuccessResponse(c, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ------- replaces every recovery code, the old ones stop working
+func Regenerate_recovery_codes(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	if !user.TwoFactor.Enabled {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if err := verifyTOTP(context.TODO(), user, user.TwoFactor.Secret, req.Code); err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	_, err = config.DB.Collection("users").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"two_factor.recovery_codes": hashes}},
+	)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save recovery codes")
+		return
+	}
+
+	auditUserChange(user, "user.recovery_codes_regenerated", user.ID, nil)
+
+	utils.SuccessResponse(c, gin.H{"recovery_codes": codes})
+}
+
+// ------- admin forces 2FA for one user, they have to enroll on their next login
+func Set_two_factor_requirement(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorRequirementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "required is required")
+		return
+	}
+
+	_, err := config.DB.Collection("users").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": target.ID},
+		bson.M{"$set": bson.M{"two_factor.required": *req.Required}},
+	)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	auditUserChange(user, "user.two_factor_required", target.ID, map[string]any{"required": *req.Required})
+
+	utils.SuccessResponse(c, gin.H{"message": "Two-factor requirement updated"})
+}
+
+// ------- lost phone and recovery codes, the user enrolls again on next login if 2FA is required
+func Reset_two_factor(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if err := clearTwoFactor(context.TODO(), target.ID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset two-factor authentication")
+		return
+	}
+
+	auditUserChange(user, "user.two_factor_reset", target.ID, nil)
+
+	utils.SuccessResponse(c, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// ------- per user flag set by an admin, or any role listed in TWO_FACTOR_REQUIRED_ROLES ("admin,hr")
+func twoFactorRequired(user models.User) bool {
+	if user.TwoFactor.Required {
+		return true
+	}
+	for _, role := range strings.Split(os.Getenv("TWO_FACTOR_REQUIRED_ROLES"), ",") {
+		if strings.TrimSpace(role) == user.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// ------- short lived token that only proves the first step (password, LDAP or SSO), AuthMiddleware refuses
+// anything with a purpose claim. method is what the session gets labelled with once the second factor passes
+func issueMFAToken(user models.User, method string) (string, error) {
+	return tokens.Sign(jwt.MapClaims{
+		"user_id": user.ID.Hex(),
+		"method":  method,
+		"purpose": mfaTokenPurpose,
+		"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+	})
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadMFAUser(c *gin.Context, tokenString string) (models.User, string, bool) {
+	var user models.User
+
+	claims, err := tokens.Parse(tokenString)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Login expired, sign in again")
+		return user, "", false
+	}
+
+	userHex, _ := claims["user_id"].(string)
+	userID, err := primitive.ObjectIDFromHex(userHex)
+	if claims["purpose"] != mfaTokenPurpose || err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+		return user, "", false
+	}
+
+	err = config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&user)
+	if err != nil || user.Status == "deactivated" {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+		return user, "", false
+	}
+
+	method, _ := claims["method"].(string)
+	if method == "" {
+		method = loginMethod(user)
+	}
+
+	return user, method, true
+}
+
+func startTwoFactorSetup(c *gin.Context, user models.User) {
+	if user.TwoFactor.Enabled {
+		utils.ErrorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+
+	_, err = config.DB.Collection("users").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": user.ID, "two_factor.enabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"two_factor.pending_secret": secret}},
+	)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start two-factor setup")
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Attendance"
+	}
+
+	utils.SuccessResponse(c, models.TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: totp.ProvisioningURI(issuer, user.Email, secret),
+	})
+}
+
+// first code from the app proves the secret was scanned, returns the recovery codes to show once
+func enableTwoFactor(ctx context.Context, user models.User, code string) ([]string, error) {
+	if lockedOut(user) {
+		return nil, errTwoFactorLocked
+	}
+
+	step, ok := totp.Validate(user.TwoFactor.PendingSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, recordTwoFactorFailure(ctx, user)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := config.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "two_factor.pending_secret": user.TwoFactor.PendingSecret},
+		bson.M{
+			"$set": bson.M{
+				"two_factor.enabled":        true,
+				"two_factor.enabled_at":     &now,
+				"two_factor.secret":         user.TwoFactor.PendingSecret,
+				"two_factor.recovery_codes": hashes,
+				"two_factor.last_step":      step,
+			},
+			"$unset": bson.M{
+				"two_factor.pending_secret":  "",
+				"two_factor.failed_attempts": "",
+				"two_factor.locked_until":    "",
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, errTwoFactorInvalid
+	}
+
+	auditUserChange(user, "user.two_factor_enabled", user.ID, nil)
+
+	return codes, nil
+}
+
+// ------- last_step in the filter means a code is accepted once, even by two requests racing
+func verifyTOTP(ctx context.Context, user models.User, secret string, code string) error {
+	if lockedOut(user) {
+		return errTwoFactorLocked
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), user.TwoFactor.LastStep)
+	if !ok {
+		return recordTwoFactorFailure(ctx, user)
+	}
+
+	result, err := config.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "two_factor.last_step": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{
+			"$set":   bson.M{"two_factor.last_step": step},
+			"$unset": bson.M{"two_factor.failed_attempts": "", "two_factor.locked_until": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errTwoFactorInvalid
+	}
+	return nil
+}
+
+func useRecoveryCode(ctx context.Context, user models.User, code string) error {
+	if lockedOut(user) {
+		return errTwoFactorLocked
+	}
+
+	hash := hashToken(normalizeRecoveryCode(code))
+	result, err := config.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": user.ID, "two_factor.recovery_codes": hash},
+		bson.M{
+			"$pull":  bson.M{"two_factor.recovery_codes": hash},
+			"$unset": bson.M{"two_factor.failed_attempts": "", "two_factor.locked_until": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return recordTwoFactorFailure(ctx, user)
+	}
+
+	auditUserChange(user, "user.recovery_code_used", user.ID, map[string]any{"remaining": len(user.TwoFactor.RecoveryCodes) - 1})
+	return nil
+}
+
+func lockedOut(user models.User) bool {
+	return user.TwoFactor.LockedUntil != nil && time.Now().Before(*user.TwoFactor.LockedUntil)
+}
+
+// counts a wrong code, maxTwoFactorAttempts in a row lock the second factor for twoFactorLockoutPeriod
+func recordTwoFactorFailure(ctx context.Context, user models.User) error {
+	update := bson.M{"$inc": bson.M{"two_factor.failed_attempts": 1}}
+	if user.TwoFactor.FailedAttempts+1 >= maxTwoFactorAttempts {
+		lockedUntil := time.Now().Add(twoFactorLockoutPeriod)
+		update = bson.M{
+			"$set":   bson.M{"two_factor.locked_until": &lockedUntil},
+			"$unset": bson.M{"two_factor.failed_attempts": ""},
+		}
+	}
+
+	if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		return err
+	}
+	return errTwoFactorInvalid
+}
+
+func clearTwoFactor(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := config.DB.Collection("users").UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set": bson.M{"two_factor.enabled": false},
+			"$unset": bson.M{
+				"two_factor.enabled_at":      "",
+				"two_factor.secret":          "",
+				"two_factor.pending_secret":  "",
+				"two_factor.recovery_codes":  "",
+				"two_factor.last_step":       "",
+				"two_factor.failed_attempts": "",
+				"two_factor.locked_until":    "",
+			},
+		},
+	)
+	return err
+}
+
+// ------- codes look like "ABCDE-FGHIJ", only their sha256 is stored
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func twoFactorErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errTwoFactorLocked):
+		utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many invalid codes, try again later")
+	case errors.Is(err, errTwoFactorInvalid):
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid code")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify code")
+	}
+}
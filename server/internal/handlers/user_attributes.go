@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxAttributeStringLength = 200
+
+// ------- keys end up in query params (attr.<key>) and CSV headers, so keep them boring
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+func Get_user_attributes(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	definitions, err := loadAttributeDefinitions(context.TODO())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+		return
+	}
+
+	utils.SuccessResponse(c, definitions)
+}
+
+// ------- a new required attribute only applies to users created or edited afterwards, existing users are not backfilled
+func Create_user_attribute(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req models.AttributeDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	req.Key = strings.TrimSpace(req.Key)
+
+	fieldErrors := validateAttributeDefinition(req)
+	if !attributeKeyPattern.MatchString(req.Key) {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "key", Message: "must start with a letter and contain only lower case letters, digits and _"})
+	}
+
+	count, err := config.DB.Collection("attribute_definitions").CountDocuments(context.TODO(), bson.M{"key": req.Key})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check attribute key")
+		return
+	}
+	if count > 0 {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "key", Message: "is already defined"})
+	}
+
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid attribute", fieldErrors)
+		return
+	}
+
+	now := time.Now()
+	definition := models.AttributeDefinition{
+		Key:           req.Key,
+		Label:         req.Label,
+		Type:          req.Type,
+		Required:      req.Required,
+		AllowedValues: req.AllowedValues,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	result, err := config.DB.Collection("attribute_definitions").InsertOne(context.TODO(), definition)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create attribute")
+		return
+	}
+	definition.ID = result.InsertedID.(primitive.ObjectID)
+
+	auditUserChange(user, "attribute.created", definition.ID, map[string]any{"key": definition.Key, "type": definition.Type})
+
+	utils.SuccessResponse(c, definition)
+}
+
+// ------- key and type are fixed once created, stored values would no longer match them
+func Update_user_attribute(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	definition, ok := loadAttributeDefinition(c)
+	if !ok {
+		return
+	}
+
+	var req models.AttributeDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	fieldErrors := validateAttributeDefinition(req)
+	if req.Key != definition.Key {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "key", Message: "cannot be changed"})
+	}
+	if req.Type != definition.Type {
+		fieldErrors = append(fieldErrors, utils.FieldError{Field: "type", Message: "cannot be changed, delete and recreate the attribute"})
+	}
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid attribute", fieldErrors)
+		return
+	}
+
+	set := bson.M{
+		"label":          req.Label,
+		"required":       req.Required,
+		"allowed_values": req.AllowedValues,
+		"updated_at":     time.Now(),
+	}
+
+	_, err := config.DB.Collection("attribute_definitions").UpdateOne(context.TODO(), bson.M{"_id": definition.ID}, bson.M{"$set": set})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update attribute")
+		return
+	}
+
+	auditUserChange(user, "attribute.updated", definition.ID, map[string]any{"key": definition.Key})
+
+	utils.SuccessResponse(c, gin.H{"message": "Attribute updated successfully"})
+}
+
+// ------- removes the value from every user too, so filters and exports don't see orphaned keys
+func Delete_user_attribute(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	definition, ok := loadAttributeDefinition(c)
+	if !ok {
+		return
+	}
+
+	err := config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		if _, err := config.DB.Collection("attribute_definitions").DeleteOne(sc, bson.M{"_id": definition.ID}); err != nil {
+			return err
+		}
+
+		_, err := config.DB.Collection("users").UpdateMany(
+			sc,
+			bson.M{"attributes." + definition.Key: bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"attributes." + definition.Key: ""}},
+		)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(sc, user, "attribute.deleted", "attribute", definition.ID, map[string]any{"key": definition.Key})
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete attribute")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Attribute deleted successfully"})
+}
+
+func validateAttributeDefinition(req models.AttributeDefinitionRequest) []utils.FieldError {
+	var errs []utils.FieldError
+
+	if strings.TrimSpace(req.Label) == "" {
+		errs = append(errs, utils.FieldError{Field: "label", Message: "is required"})
+	}
+
+	if !slices.Contains(models.AttributeTypes, req.Type) {
+		errs = append(errs, utils.FieldError{Field: "type", Message: "must be one of " + strings.Join(models.AttributeTypes, ", ")})
+	}
+
+	switch {
+	case req.Type == "enum" && len(req.AllowedValues) == 0:
+		errs = append(errs, utils.FieldError{Field: "allowed_values", Message: "required for enum attributes"})
+	case req.Type != "enum" && len(req.AllowedValues) > 0:
+		errs = append(errs, utils.FieldError{Field: "allowed_values", Message: "only allowed for enum attributes"})
+	}
+
+	for i, value := range req.AllowedValues {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, utils.FieldError{Field: "allowed_values[" + strconv.Itoa(i) + "]", Message: "cannot be empty"})
+		}
+	}
+
+	return errs
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadAttributeDefinition(c *gin.Context) (models.AttributeDefinition, bool) {
+	var definition models.AttributeDefinition
+
+	definitionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid attribute ID")
+		return definition, false
+	}
+
+	err = config.DB.Collection("attribute_definitions").FindOne(context.TODO(), bson.M{"_id": definitionID}).Decode(&definition)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Attribute not found")
+		return definition, false
+	}
+
+	return definition, true
+}
+
+func loadAttributeDefinitions(ctx context.Context) ([]models.AttributeDefinition, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "key", Value: 1}})
+	cursor, err := config.DB.Collection("attribute_definitions").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	definitions := []models.AttributeDefinition{}
+	if err = cursor.All(ctx, &definitions); err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// ------- merges changes into current, a null or empty value removes the key.
+// required attributes are checked on the merged result, so partial updates only have to send what changed
+func applyAttributes(definitions []models.AttributeDefinition, current map[string]any, changes map[string]any) (map[string]any, []utils.FieldError) {
+	var errs []utils.FieldError
+
+	merged := maps.Clone(current)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+
+	byKey := map[string]models.AttributeDefinition{}
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+
+	for key, raw := range changes {
+		field := "attributes." + key
+
+		definition, ok := byKey[key]
+		if !ok {
+			errs = append(errs, utils.FieldError{Field: field, Message: "is not a defined attribute"})
+			continue
+		}
+
+		if raw == nil || raw == "" {
+			delete(merged, key)
+			continue
+		}
+
+		value, message := attributeValue(definition, raw)
+		if message != "" {
+			errs = append(errs, utils.FieldError{Field: field, Message: message})
+			continue
+		}
+		merged[key] = value
+	}
+
+	for _, definition := range definitions {
+		if _, ok := merged[definition.Key]; definition.Required && !ok {
+			errs = append(errs, utils.FieldError{Field: "attributes." + definition.Key, Message: "is required"})
+		}
+	}
+
+	return merged, errs
+}
+
+// ------- JSON gives typed values, CSV and query params give strings, both end up stored as the definition's type
+func attributeValue(definition models.AttributeDefinition, raw any) (any, string) {
+	text, isString := raw.(string)
+	text = strings.TrimSpace(text)
+
+	switch definition.Type {
+	case "number":
+		switch v := raw.(type) {
+		case float64:
+			return v, ""
+		case string:
+			if n, err := strconv.ParseFloat(text, 64); err == nil {
+				return n, ""
+			}
+		}
+		return nil, "must be a number"
+
+	case "boolean":
+		switch v := raw.(type) {
+		case bool:
+			return v, ""
+		case string:
+			if b, err := strconv.ParseBool(text); err == nil {
+				return b, ""
+			}
+		}
+		return nil, "must be true or false"
+
+	case "date":
+		if _, err := time.Parse("2006-01-02", text); !isString || err != nil {
+			return nil, "must be a date like 2006-01-02"
+		}
+		return text, ""
+
+	case "enum":
+		if !isString || !slices.Contains(definition.AllowedValues, text) {
+			return nil, "must be one of " + strings.Join(definition.AllowedValues, ", ")
+		}
+		return text, ""
+
+	default:
+		if !isString {
+			return nil, "must be text"
+		}
+		if len(text) > maxAttributeStringLength {
+			return nil, "must be at most " + strconv.Itoa(maxAttributeStringLength) + " characters"
+		}
+		return text, ""
+	}
+}
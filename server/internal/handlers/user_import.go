@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxImportBytes = 2 << 20
+	maxImportRows  = 1000
+)
+
+var importColumns = []string{
+	"name", "email", "role", "manager", "site", "shift", "timezone",
+	"employee_code", "department", "job_title", "employment_type", "joining_date", "exit_date",
+}
+
+type importRow struct {
+	line       int
+	fields     map[string]string
+	attributes map[string]any
+	errors     []utils.FieldError
+}
+
+// ------- multipart "file" with a header row naming any of importColumns or attr.<key>, name and email are required.
+// ?dry_run=true only validates. manager is an email of an existing user or of a row further up the file.
+// every row is created in its own transaction with its invitation, so one bad row doesn't stop the rest
+func Import_users(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if !canGrantAnyRole(user) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "CSV file is required")
+		return
+	}
+	if header.Size > maxImportBytes {
+		utils.ErrorResponse(c, http.StatusBadRequest, "CSV file is too large")
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	rows, err := parseImportCSV(file)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := existingUsersByEmail(rows)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check existing users")
+		return
+	}
+
+	takenCodes, err := takenEmployeeCodes(rows)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check employee codes")
+		return
+	}
+
+	definitions, err := loadAttributeDefinitions(context.TODO())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+		return
+	}
+
+	validateImportRows(user, rows, existing, takenCodes, definitions)
+
+	results := make([]models.ImportRowResult, 0, len(rows))
+	imported := map[string]primitive.ObjectID{}
+	summary := map[string]int{}
+
+	for _, row := range rows {
+		result := models.ImportRowResult{Row: row.line, Email: row.fields["email"]}
+
+		switch {
+		case len(row.errors) > 0:
+			result.Result = "invalid"
+			result.Errors = row.errors
+		case dryRun:
+			result.Result = "valid"
+		default:
+			importUserRow(user, row, existing, imported, &result)
+		}
+
+		summary[result.Result]++
+		results = append(results, result)
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"dry_run": dryRun,
+		"total":   len(rows),
+		"summary": summary,
+		"rows":    results,
+	})
+}
+
+func parseImportCSV(r io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	headerRow, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV file is empty or unreadable")
+	}
+
+	columns := map[string]int{}
+	for i, name := range headerRow {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.New("CSV header must contain " + required)
+		}
+	}
+
+	var rows []*importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("CSV is malformed on line %d", parseErr.Line)
+			}
+			return nil, errors.New("CSV file is unreadable")
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("CSV can have at most %d rows", maxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := &importRow{line: line, fields: map[string]string{}}
+		for name, i := range columns {
+			if (slices.Contains(importColumns, name) || strings.HasPrefix(name, "attr.")) && i < len(record) {
+				row.fields[name] = strings.TrimSpace(record[i])
+			}
+		}
+		row.fields["email"] = normalizeEmail(row.fields["email"])
+		row.fields["manager"] = normalizeEmail(row.fields["manager"])
+		if row.fields["role"] == "" {
+			row.fields["role"] = models.RoleEmployee
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// one query for every email and manager email in the file
+func existingUsersByEmail(rows []*importRow) (map[string]models.User, error) {
+	var emails bson.A
+	for _, row := range rows {
+		emails = append(emails, row.fields["email"])
+		if row.fields["manager"] != "" {
+			emails = append(emails, row.fields["manager"])
+		}
+	}
+
+	existing := map[string]models.User{}
+	if len(emails) == 0 {
+		return existing, nil
+	}
+
+	cursor, err := config.DB.Collection("users").Find(context.TODO(), bson.M{"email": bson.M{"$in": emails}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var users []models.User
+	if err = cursor.All(context.TODO(), &users); err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		existing[u.Email] = u
+	}
+
+	return existing, nil
+}
+
+func takenEmployeeCodes(rows []*importRow) (map[string]bool, error) {
+	var codes bson.A
+	for _, row := range rows {
+		if row.fields["employee_code"] != "" {
+			codes = append(codes, row.fields["employee_code"])
+		}
+	}
+
+	taken := map[string]bool{}
+	if len(codes) == 0 {
+		return taken, nil
+	}
+
+	values, err := config.DB.Collection("users").Distinct(context.TODO(), "employee_code", bson.M{"employee_code": bson.M{"$in": codes}})
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if code, ok := value.(string); ok {
+			taken[code] = true
+		}
+	}
+
+	return taken, nil
+}
+
+func importProfile(row *importRow) models.EmployeeProfile {
+	return models.EmployeeProfile{
+		EmployeeCode:   row.fields["employee_code"],
+		Department:     row.fields["department"],
+		JobTitle:       row.fields["job_title"],
+		EmploymentType: row.fields["employment_type"],
+		JoiningDate:    row.fields["joining_date"],
+		ExitDate:       row.fields["exit_date"],
+	}
+}
+
+func validateImportRows(granter models.User, rows []*importRow, existing map[string]models.User, takenCodes map[string]bool, definitions []models.AttributeDefinition) {
+	seen := map[string]bool{}
+	seenCodes := map[string]bool{}
+
+	for _, row := range rows {
+		email := row.fields["email"]
+
+		if row.fields["name"] == "" {
+			row.errors = append(row.errors, utils.FieldError{Field: "name", Message: "is required"})
+		}
+		row.errors = append(row.errors, validateEmail(email)...)
+		row.errors = append(row.errors, validateRole(granter, row.fields["role"])...)
+		row.errors = append(row.errors, validateShift(row.fields["shift"])...)
+		row.errors = append(row.errors, validateTimezone(row.fields["timezone"])...)
+		row.errors = append(row.errors, validateProfile(importProfile(row))...)
+
+		changes := map[string]any{}
+		for name, value := range row.fields {
+			if key, ok := strings.CutPrefix(name, "attr."); ok {
+				changes[key] = value
+			}
+		}
+		var attributeErrors []utils.FieldError
+		row.attributes, attributeErrors = applyAttributes(definitions, nil, changes)
+		row.errors = append(row.errors, attributeErrors...)
+
+		if code := row.fields["employee_code"]; code != "" {
+			if seenCodes[code] {
+				row.errors = append(row.errors, utils.FieldError{Field: "employee_code", Message: "appears more than once in the file"})
+			} else if takenCodes[code] {
+				row.errors = append(row.errors, utils.FieldError{Field: "employee_code", Message: "is already used by another user"})
+			}
+			seenCodes[code] = true
+		}
+
+		if seen[email] {
+			row.errors = append(row.errors, utils.FieldError{Field: "email", Message: "appears more than once in the file"})
+		} else if _, ok := existing[email]; ok {
+			row.errors = append(row.errors, utils.FieldError{Field: "email", Message: "user already exists"})
+		}
+
+		if manager := row.fields["manager"]; manager != "" {
+			_, managerExists := existing[manager]
+			if manager == email {
+				row.errors = append(row.errors, utils.FieldError{Field: "manager", Message: "cannot be the user themselves"})
+			} else if !managerExists && !seen[manager] {
+				row.errors = append(row.errors, utils.FieldError{Field: "manager", Message: "must be an existing user or a row earlier in the file"})
+			}
+		}
+
+		seen[email] = true
+	}
+}
+
+func importUserRow(granter models.User, row *importRow, existing map[string]models.User, imported map[string]primitive.ObjectID, result *models.ImportRowResult) {
+	var managerID *primitive.ObjectID
+	if manager := row.fields["manager"]; manager != "" {
+		if u, ok := existing[manager]; ok {
+			managerID = &u.ID
+		} else if id, ok := imported[manager]; ok {
+			managerID = &id
+		} else {
+			result.Result = "invalid"
+			result.Errors = []utils.FieldError{{Field: "manager", Message: "manager row was not imported"}}
+			return
+		}
+	}
+
+	newUser := models.User{
+		ID:        primitive.NewObjectID(),
+		Email:     row.fields["email"],
+		Name:      row.fields["name"],
+		Role:      row.fields["role"],
+		ManagerID: managerID,
+		Site:      row.fields["site"],
+		Shift:     row.fields["shift"],
+		Timezone:  row.fields["timezone"],
+		Status:    "invited",
+		CreatedAt: time.Now(),
+
+		EmployeeProfile: importProfile(row),
+
+		Attributes: row.attributes,
+	}
+
+	var token string
+	err := config.WithTransaction(context.TODO(), func(sc mongo.SessionContext) error {
+		if _, err := config.DB.Collection("users").InsertOne(sc, newUser); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = createInvitation(sc, newUser, granter)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(sc, granter, "user.imported", "user", newUser.ID, map[string]any{"row": row.line})
+	})
+
+	if mongo.IsDuplicateKeyError(err) {
+		result.Result = "invalid"
+		result.Errors = []utils.FieldError{{Field: "email", Message: "user already exists"}}
+		return
+	}
+	if err != nil {
+		log.Printf("Importing row %d failed: %v", row.line, err)
+		result.Result = "failed"
+		return
+	}
+
+	imported[newUser.Email] = newUser.ID
+	result.Result = "imported"
+	result.UserID = newUser.ID.Hex()
+
+	result.Invite = "sent"
+	if err := sendInvitation(newUser, token); err != nil {
+		result.Invite = "failed"
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/mail"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const minPasswordLength = 8
+
+// ------- which roles each role may hand out. override with ROLE_GRANT_POLICY="admin:employee,manager,hr,admin;hr:employee"
+var defaultGrantPolicy = map[string][]string{
+	models.RoleAdmin: {models.RoleEmployee, models.RoleManager, models.RoleHR, models.RoleAdmin},
+	models.RoleHR:    {models.RoleEmployee},
+}
+
+// read per call, so .env loaded in main is picked up
+func grantPolicy() map[string][]string {
+	raw := os.Getenv("ROLE_GRANT_POLICY")
+	if raw == "" {
+		return defaultGrantPolicy
+	}
+
+	policy := map[string][]string{}
+	for _, entry := range strings.Split(raw, ";") {
+		granter, grants, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !slices.Contains(models.Roles, granter) {
+			log.Printf("Ignoring invalid ROLE_GRANT_POLICY entry %q", entry)
+			continue
+		}
+		for _, role := range strings.Split(grants, ",") {
+			role = strings.TrimSpace(role)
+			if slices.Contains(models.Roles, role) {
+				policy[granter] = append(policy[granter], role)
+			}
+		}
+	}
+	return policy
+}
+
+func canGrantAnyRole(granter models.User) bool {
+	return len(grantPolicy()[granter.Role]) > 0
+}
+
+func validateRole(granter models.User, role string) []utils.FieldError {
+	if !slices.Contains(models.Roles, role) {
+		return []utils.FieldError{{Field: "role", Message: "must be one of " + strings.Join(models.Roles, ", ")}}
+	}
+	if granter.APIKey && slices.Contains(models.PrivilegedRoles, role) {
+		return []utils.FieldError{{Field: "role", Message: "API keys cannot grant the " + role + " role"}}
+	}
+	if !slices.Contains(grantPolicy()[granter.Role], role) {
+		return []utils.FieldError{{Field: "role", Message: "you are not allowed to grant the " + role + " role"}}
+	}
+	return nil
+}
+
+// ------- emails are stored lower case, the unique index and every lookup by email compare them as is
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validateEmail(email string) []utils.FieldError {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return []utils.FieldError{{Field: "email", Message: "must be a valid email address"}}
+	}
+	return nil
+}
+
+func validatePassword(password string) []utils.FieldError {
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+
+	if len(password) < minPasswordLength || !hasUpper || !hasLower || !hasDigit {
+		return []utils.FieldError{{Field: "password", Message: "must be at least 8 characters with upper case, lower case and a digit"}}
+	}
+	return nil
+}
+
+// ------- "HH:MM-HH:MM", overnight shifts like "22:00-06:00" are fine
+func validateShift(shift string) []utils.FieldError {
+	if shift == "" {
+		return nil
+	}
+
+	start, end, ok := strings.Cut(shift, "-")
+	if ok {
+		_, startErr := time.Parse("15:04", strings.TrimSpace(start))
+		_, endErr := time.Parse("15:04", strings.TrimSpace(end))
+		ok = startErr == nil && endErr == nil
+	}
+	if !ok {
+		return []utils.FieldError{{Field: "shift", Message: "must look like 09:00-18:00"}}
+	}
+	return nil
+}
+
+func validateTimezone(timezone string) []utils.FieldError {
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return []utils.FieldError{{Field: "timezone", Message: "must be an IANA time zone like Asia/Kolkata"}}
+	}
+	return nil
+}
+
+func validateProfile(profile models.EmployeeProfile) []utils.FieldError {
+	var errs []utils.FieldError
+
+	if profile.EmploymentType != "" && !slices.Contains(models.EmploymentTypes, profile.EmploymentType) {
+		errs = append(errs, utils.FieldError{Field: "employment_type", Message: "must be one of " + strings.Join(models.EmploymentTypes, ", ")})
+	}
+
+	joining, joiningErr := time.Parse("2006-01-02", profile.JoiningDate)
+	if profile.JoiningDate != "" && joiningErr != nil {
+		errs = append(errs, utils.FieldError{Field: "joining_date", Message: "must be a date like 2006-01-02"})
+	}
+
+	exit, exitErr := time.Parse("2006-01-02", profile.ExitDate)
+	if profile.ExitDate != "" && exitErr != nil {
+		errs = append(errs, utils.FieldError{Field: "exit_date", Message: "must be a date like 2006-01-02"})
+	}
+
+	if profile.JoiningDate != "" && profile.ExitDate != "" && joiningErr == nil && exitErr == nil && exit.Before(joining) {
+		errs = append(errs, utils.FieldError{Field: "exit_date", Message: "cannot be before joining_date"})
+	}
+
+	return errs
+}
+
+// ------- employee codes are unique, but optional
+func employeeCodeTaken(ctx context.Context, code string, except primitive.ObjectID) (bool, error) {
+	if code == "" {
+		return false, nil
+	}
+
+	count, err := config.DB.Collection("users").CountDocuments(ctx, bson.M{"employee_code": code, "_id": bson.M{"$ne": except}})
+	return count > 0, err
+}
+
+// ------- checked here rather than left to the unique index, databases from before it can lack it.
+// email is the login name and what SSO and LDAP link accounts by, two users can't share one
+func emailTaken(ctx context.Context, email string, except primitive.ObjectID) (bool, error) {
+	count, err := config.DB.Collection("users").CountDocuments(ctx, bson.M{"email": email, "_id": bson.M{"$ne": except}})
+	return count > 0, err
+}
+
+// ------- attendance days have to fall inside the employment period
+func employmentDateError(user models.User, day string) string {
+	if user.JoiningDate != "" && day < user.JoiningDate {
+		return "Attendance is not allowed before your joining date " + user.JoiningDate
+	}
+	if user.ExitDate != "" && day > user.ExitDate {
+		return "Attendance is not allowed after your exit date " + user.ExitDate
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		password string
+		wantOK   bool
+	}{
+		{"Passw0rd", true},
+		{"correct Horse 9 battery", true},
+		{"Pass0rd", false},
+		{"password1", false},
+		{"PASSWORD1", false},
+		{"Password", false},
+		{"12345678", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := validatePassword(tt.password); (len(got) == 0) != tt.wantOK {
+			t.Errorf("validatePassword(%q) = %+v, want ok %v", tt.password, got, tt.wantOK)
+		}
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email  string
+		wantOK bool
+	}{
+		{"jane@example.com", true},
+		{"jane.doe+work@mail.example.co.in", true},
+		{"a@b.c", true},
+		{"jane@localhost", false},
+		{"Jane Doe <jane@example.com>", false},
+		{" jane@example.com", false},
+		{"jane@", false},
+		{"@example.com", false},
+		{"jane", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := validateEmail(tt.email); (len(got) == 0) != tt.wantOK {
+			t.Errorf("validateEmail(%q) = %+v, want ok %v", tt.email, got, tt.wantOK)
+		}
+	}
+}
+
+func TestValidateRole(t *testing.T) {
+	admin := models.User{Role: models.RoleAdmin}
+	hr := models.User{Role: models.RoleHR}
+	manager := models.User{Role: models.RoleManager}
+	adminKey := models.User{Role: models.RoleAdmin, APIKey: true}
+
+	tests := []struct {
+		name    string
+		policy  string
+		granter models.User
+		role    string
+		want    string // ---------------- the error message, empty when the role may be granted
+	}{
+		{"admin grants admin", "", admin, models.RoleAdmin, ""},
+		{"admin grants employee", "", admin, models.RoleEmployee, ""},
+		{"hr grants employee", "", hr, models.RoleEmployee, ""},
+		{"hr grants manager", "", hr, models.RoleManager, "you are not allowed to grant the manager role"},
+		{"hr grants admin", "", hr, models.RoleAdmin, "you are not allowed to grant the admin role"},
+		{"manager grants nothing", "", manager, models.RoleEmployee, "you are not allowed to grant the employee role"},
+		{"unknown role", "", admin, "owner", "must be one of employee, manager, hr, admin"},
+		{"empty role", "", admin, "", "must be one of employee, manager, hr, admin"},
+		{"api key grants employee", "", adminKey, models.RoleEmployee, ""},
+		{"api key grants manager", "", adminKey, models.RoleManager, ""},
+		{"api key grants hr", "", adminKey, models.RoleHR, "API keys cannot grant the hr role"},
+		{"api key grants admin", "", adminKey, models.RoleAdmin, "API keys cannot grant the admin role"},
+		{"policy lets hr grant managers", "admin:employee,manager,hr,admin;hr:employee,manager", hr, models.RoleManager, ""},
+		{"policy takes admin away from admins", "admin:employee,manager,hr", admin, models.RoleAdmin, "you are not allowed to grant the admin role"},
+		{"policy can't open privileged roles to keys", "admin:employee,manager,hr,admin", adminKey, models.RoleAdmin, "API keys cannot grant the admin role"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ROLE_GRANT_POLICY", tt.policy)
+
+			got := validateRole(tt.granter, tt.role)
+			var want []utils.FieldError
+			if tt.want != "" {
+				want = []utils.FieldError{{Field: "role", Message: tt.want}}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("validateRole() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ------- ?search=&role=&status=&department=&site=&employment_type=&job_title=&employee_code=&attr.<key>=&page=&limit=
+// search matches name, email or employee code
+func List_users(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	page, limit := pagination(c)
+
+	filter := bson.M{}
+	if search := c.Query("search"); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"name": pattern},
+			bson.M{"email": pattern},
+			bson.M{"employee_code": pattern},
+		}
+	}
+	if role := c.Query("role"); role != "" {
+		filter["role"] = role
+	}
+	for _, key := range []string{"department", "site", "employment_type", "job_title", "employee_code"} {
+		if value := c.Query(key); value != "" {
+			filter[key] = value
+		}
+	}
+	attributeFilter, ok := attributeQueryFilter(c)
+	if !ok {
+		return
+	}
+	maps.Copy(filter, attributeFilter)
+
+	switch status := c.Query("status"); status {
+	case "":
+	case "active":
+		filter["status"] = bson.M{"$in": bson.A{"active", nil}}
+	default:
+		filter["status"] = status
+	}
+
+	total, err := config.DB.Collection("users").CountDocuments(context.TODO(), filter)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to count users")
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := config.DB.Collection("users").Find(context.TODO(), filter, opts)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	users := []models.User{}
+	if err = cursor.All(context.TODO(), &users); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to decode users")
+		return
+	}
+
+	utils.SuccessResponse(c, models.UserListResponse{
+		Users: users,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+func Get_user(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, target)
+}
+
+func Update_user(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if !canManageWithKey(c, user, target) {
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"updated_at": &now}
+	unset := bson.M{}
+
+	var fieldErrors []utils.FieldError
+	if req.Email != nil {
+		email := normalizeEmail(*req.Email)
+		fieldErrors = append(fieldErrors, validateEmail(email)...)
+		set["email"] = email
+	}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			fieldErrors = append(fieldErrors, utils.FieldError{Field: "name", Message: "cannot be empty"})
+		}
+		set["name"] = *req.Name
+	}
+	if req.Role != nil && *req.Role != target.Role {
+		if target.ID == user.ID {
+			fieldErrors = append(fieldErrors, utils.FieldError{Field: "role", Message: "you cannot change your own role"})
+		}
+		fieldErrors = append(fieldErrors, validateRole(user, *req.Role)...)
+		set["role"] = *req.Role
+	}
+	if req.Shift != nil {
+		fieldErrors = append(fieldErrors, validateShift(*req.Shift)...)
+		set["shift"] = *req.Shift
+	}
+	if req.Timezone != nil {
+		fieldErrors = append(fieldErrors, validateTimezone(*req.Timezone)...)
+		set["timezone"] = *req.Timezone
+	}
+	if req.Site != nil {
+		set["site"] = *req.Site
+	}
+
+	// ------- validate the profile as it will look after the update, exit_date is checked against the stored joining_date
+	profile := target.EmployeeProfile
+	profileFields := []struct {
+		key   string
+		value *string
+		dst   *string
+	}{
+		{"employee_code", req.EmployeeCode, &profile.EmployeeCode},
+		{"department", req.Department, &profile.Department},
+		{"job_title", req.JobTitle, &profile.JobTitle},
+		{"employment_type", req.EmploymentType, &profile.EmploymentType},
+		{"joining_date", req.JoiningDate, &profile.JoiningDate},
+		{"exit_date", req.ExitDate, &profile.ExitDate},
+	}
+	for _, field := range profileFields {
+		if field.value != nil {
+			*field.dst = strings.TrimSpace(*field.value)
+			set[field.key] = *field.dst
+		}
+	}
+	fieldErrors = append(fieldErrors, validateProfile(profile)...)
+
+	if email, ok := set["email"].(string); ok && email != target.Email {
+		taken, err := emailTaken(context.TODO(), email, target.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check email")
+			return
+		}
+		if taken {
+			fieldErrors = append(fieldErrors, utils.FieldError{Field: "email", Message: "is already used by another user"})
+		}
+	}
+
+	if req.EmployeeCode != nil {
+		taken, err := employeeCodeTaken(context.TODO(), profile.EmployeeCode, target.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check employee code")
+			return
+		}
+		if taken {
+			fieldErrors = append(fieldErrors, utils.FieldError{Field: "employee_code", Message: "is already used by another user"})
+		}
+	}
+
+	if req.Attributes != nil {
+		definitions, err := loadAttributeDefinitions(context.TODO())
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch attributes")
+			return
+		}
+		attributes, attributeErrors := applyAttributes(definitions, target.Attributes, req.Attributes)
+		fieldErrors = append(fieldErrors, attributeErrors...)
+		if len(attributes) > 0 {
+			set["attributes"] = attributes
+		} else {
+			unset["attributes"] = ""
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid user details", fieldErrors)
+		return
+	}
+
+	if req.Group != nil {
+		set["group"] = *req.Group
+	}
+	if req.ManagerID != nil {
+		if *req.ManagerID == "" {
+			unset["manager_id"] = ""
+		} else {
+			managerID, err := primitive.ObjectIDFromHex(*req.ManagerID)
+			if err != nil || managerID == target.ID {
+				utils.ErrorResponse(c, http.StatusBadRequest, "Invalid manager ID")
+				return
+			}
+			set["manager_id"] = managerID
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	_, err := config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": target.ID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "User already exists")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	changed := map[string]any{}
+	for key := range set {
+		if key != "updated_at" {
+			changed[key] = set[key]
+		}
+	}
+	for key := range unset {
+		changed[key] = nil
+	}
+	auditUserChange(user, "user.updated", target.ID, changed)
+
+	utils.SuccessResponse(c, gin.H{"message": "User updated successfully"})
+}
+
+func Deactivate_user(c *gin.Context) {
+	setUserStatus(c, "deactivated")
+}
+
+func Reactivate_user(c *gin.Context) {
+	setUserStatus(c, "active")
+}
+
+// ------- only the account goes, attendance and corrections stay for payroll history
+func Delete_user(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if target.ID == user.ID {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You cannot delete your own account")
+		return
+	}
+
+	_, err := config.DB.Collection("users").DeleteOne(context.TODO(), bson.M{"_id": target.ID})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete user")
+		return
+	}
+
+	auditUserChange(user, "user.deleted", target.ID, map[string]any{"email": target.Email})
+
+	utils.SuccessResponse(c, gin.H{"message": "User deleted successfully"})
+}
+
+func Reset_user_password(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	if fieldErrors := validatePassword(req.Password); len(fieldErrors) > 0 {
+		utils.ValidationErrorResponse(c, "Invalid password", fieldErrors)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	now := time.Now()
+	_, err = config.DB.Collection("users").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": target.ID},
+		bson.M{"$set": bson.M{"password": string(hashedPassword), "updated_at": &now}},
+	)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	auditUserChange(user, "user.password_reset", target.ID, nil)
+
+	// ------- whoever knew the old password is signed out too
+	if _, err := revokeSessions(context.TODO(), user, target.ID, bson.M{}); err != nil {
+		log.Printf("Failed to revoke sessions of %s: %v", target.ID.Hex(), err)
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Password reset successfully"})
+}
+
+func setUserStatus(c *gin.Context, status string) {
+	user := c.MustGet("user").(models.User)
+
+	if user.Role != "admin" {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	target, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if target.ID == user.ID {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You cannot change your own status")
+		return
+	}
+
+	if !canManageWithKey(c, user, target) {
+		return
+	}
+
+	now := time.Now()
+	// ------- an admin decision, so the directory sync won't undo it
+	update := bson.M{
+		"$set":   bson.M{"status": status, "updated_at": &now, "deactivated_at": &now},
+		"$unset": bson.M{"deactivated_by": ""},
+	}
+	if status == "active" {
+		update = bson.M{
+			"$set":   bson.M{"status": status, "updated_at": &now},
+			"$unset": bson.M{"deactivated_at": "", "deactivated_by": ""},
+		}
+	}
+
+	_, err := config.DB.Collection("users").UpdateOne(context.TODO(), bson.M{"_id": target.ID}, update)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user status")
+		return
+	}
+
+	action := "user.deactivated"
+	if status == "active" {
+		action = "user.reactivated"
+	}
+	auditUserChange(user, action, target.ID, nil)
+
+	if status == "deactivated" {
+		if _, err := revokeSessions(context.TODO(), user, target.ID, bson.M{}); err != nil {
+			log.Printf("Failed to revoke sessions of %s: %v", target.ID.Hex(), err)
+		}
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "User status updated to " + status})
+}
+
+// ------- an API key only manages ordinary accounts, otherwise it could take over an admin by changing
+// their email or lock admins out. writes the error response itself
+func canManageWithKey(c *gin.Context, actor models.User, target models.User) bool {
+	if actor.APIKey && slices.Contains(models.PrivilegedRoles, target.Role) {
+		utils.ErrorResponse(c, http.StatusForbidden, "API keys cannot modify admin or HR accounts")
+		return false
+	}
+	return true
+}
+
+// writes the error response itself, callers just return when ok is false
+func loadUser(c *gin.Context) (models.User, bool) {
+	var target models.User
+
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return target, false
+	}
+
+	err = config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": userID}).Decode(&target)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return target, false
+	}
+
+	return target, true
+}
+
+// ------- user admin changes are already applied, a failed audit write is logged rather than failing the request
+func auditUserChange(actor models.User, action string, userID primitive.ObjectID, details map[string]any) {
+	if err := recordAudit(context.TODO(), actor, action, "user", userID, details); err != nil {
+		logAuditFailure(action, userID, err)
+	}
+}
+
+// ------- ?page=1&limit=20, limit capped at 100
+func pagination(c *gin.Context) (int64, int64) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	return page, limit
+}
@@ -3,21 +3,27 @@ package middleware
 import (
 	"context"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/Sourav01112/server/internal/config"
 	"github.com/Sourav01112/server/internal/models"
+	"github.com/Sourav01112/server/internal/tokens"
 	"github.com/Sourav01112/server/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// ------- machine clients send an API key, either as X-API-Key or as the bearer token
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Authorization header required")
@@ -26,25 +32,21 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
-			c.Abort()
+		if strings.HasPrefix(tokenString, APIKeyPrefix) {
+			authenticateAPIKey(c, tokenString)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+		// ------- the 2FA step token carries a purpose and is not a session
+		claims, err := tokens.Parse(tokenString)
+		if err != nil || claims["purpose"] != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
 			c.Abort()
 			return
 		}
 
-		userID, err := primitive.ObjectIDFromHex(claims["user_id"].(string))
+		userHex, _ := claims["user_id"].(string)
+		userID, err := primitive.ObjectIDFromHex(userHex)
 		if err != nil {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid user ID")
 			c.Abort()
@@ -59,7 +61,54 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if user.Status == "deactivated" {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Account is deactivated")
+			c.Abort()
+			return
+		}
+
+		session, ok := loadSession(c, claims, userID)
+		if !ok {
+			return
+		}
+
 		c.Set("user", user)
+		c.Set("session", session)
 		c.Next()
 	}
 }
+
+// ------- the session behind the token must still be open. last_seen_at moves at most once a minute
+func loadSession(c *gin.Context, claims map[string]any, userID primitive.ObjectID) (models.Session, bool) {
+	var session models.Session
+
+	sidHex, _ := claims["sid"].(string)
+	sessionID, err := primitive.ObjectIDFromHex(sidHex)
+	if err != nil {
+		abort(c, http.StatusUnauthorized, "Invalid token")
+		return session, false
+	}
+
+	err = config.DB.Collection("sessions").FindOne(context.TODO(), bson.M{"_id": sessionID, "user_id": userID}).Decode(&session)
+	if err != nil || session.RevokedAt != nil {
+		abort(c, http.StatusUnauthorized, "Session has been signed out")
+		return session, false
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= lastUsedResolution || session.LastSeenIP != c.ClientIP() {
+		_, err = config.DB.Collection("sessions").UpdateOne(
+			context.TODO(),
+			bson.M{"_id": session.ID},
+			bson.M{"$set": bson.M{"last_seen_at": now, "last_seen_ip": c.ClientIP()}},
+		)
+		if err != nil {
+			abort(c, http.StatusInternalServerError, "Failed to update session")
+			return session, false
+		}
+		session.LastSeenAt = now
+		session.LastSeenIP = c.ClientIP()
+	}
+
+	return session, true
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// a key still marked running after this long belongs to a request that died, the next one takes it over
+const idempotencyLockTimeout = time.Minute
+
+// ------- with an Idempotency-Key header the first response for that key is stored and sent again for
+// repeats (Idempotent-Replayed: true), so a double tap or a retry after a dropped connection punches once.
+// reusing a key for a different body is refused, server errors aren't kept so they can be retried
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			abort(c, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abort(c, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		record, replay, ok := claimIdempotencyKey(c, idempotencyScope(c), key, requestHash)
+		if !ok {
+			return
+		}
+		if replay {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.Status, record.ContentType, []byte(record.Body))
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		keys := config.DB.Collection("idempotency_keys")
+		if writer.Status() >= http.StatusInternalServerError {
+			keys.DeleteOne(context.TODO(), bson.M{"_id": record.ID})
+			return
+		}
+
+		now := time.Now()
+		keys.UpdateOne(context.TODO(), bson.M{"_id": record.ID}, bson.M{"$set": bson.M{
+			"status":       writer.Status(),
+			"content_type": writer.Header().Get("Content-Type"),
+			"body":         writer.body.String(),
+			"completed_at": &now,
+		}})
+	}
+}
+
+// ------- inserts the key as running, or loads the earlier request's record. replay is true when that
+// one finished and its response should be sent again, ok is false when an error response was written
+func claimIdempotencyKey(c *gin.Context, scope string, key string, requestHash string) (record models.IdempotencyKey, replay bool, ok bool) {
+	keys := config.DB.Collection("idempotency_keys")
+	now := time.Now()
+
+	record = models.IdempotencyKey{Scope: scope, Key: key, RequestHash: requestHash, CreatedAt: now}
+	result, err := keys.InsertOne(context.TODO(), record)
+	if err == nil {
+		record.ID = result.InsertedID.(primitive.ObjectID)
+		return record, false, true
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		abort(c, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+		return record, false, false
+	}
+
+	var existing models.IdempotencyKey
+	if err := keys.FindOne(context.TODO(), bson.M{"scope": scope, "key": key}).Decode(&existing); err != nil {
+		abort(c, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+		return record, false, false
+	}
+	if existing.RequestHash != requestHash {
+		abort(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return record, false, false
+	}
+	if existing.CompletedAt != nil {
+		return existing, true, true
+	}
+
+	// ------- only one retry can take over an abandoned key, the created_at filter makes it a compare-and-set
+	taken, err := keys.UpdateOne(context.TODO(),
+		bson.M{"_id": existing.ID, "completed_at": nil, "created_at": bson.M{"$lt": now.Add(-idempotencyLockTimeout)}},
+		bson.M{"$set": bson.M{"created_at": now}},
+	)
+	if err != nil {
+		abort(c, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+		return record, false, false
+	}
+	if taken.MatchedCount == 0 {
+		abort(c, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+		return record, false, false
+	}
+	return existing, false, true
+}
+
+// ------- keys are per user (or API key), kiosk routes have no user so they are per kiosk
+func idempotencyScope(c *gin.Context) string {
+	if user, ok := c.Get("user"); ok {
+		return "user:" + user.(models.User).ID.Hex()
+	}
+	if kiosk, ok := c.Get("kiosk"); ok {
+		return "kiosk:" + kiosk.(models.Kiosk).ID.Hex()
+	}
+	return "anonymous"
+}
+
+// keeps a copy of the response body for storing
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ------- kiosk endpoints take the token the kiosk got at registration in X-Kiosk-Token, not a user token
+func KioskMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-Kiosk-Token")
+		if token == "" {
+			abort(c, http.StatusUnauthorized, "Kiosk token required")
+			return
+		}
+
+		sum := sha256.Sum256([]byte(token))
+
+		var kiosk models.Kiosk
+		err := config.DB.Collection("kiosks").FindOne(context.TODO(), bson.M{"token_hash": hex.EncodeToString(sum[:])}).Decode(&kiosk)
+		if err != nil {
+			abort(c, http.StatusUnauthorized, "Invalid kiosk token")
+			return
+		}
+		if !kiosk.Active {
+			abort(c, http.StatusForbidden, "Kiosk is disabled")
+			return
+		}
+
+		now := time.Now()
+		if kiosk.LastSeenAt == nil || now.Sub(*kiosk.LastSeenAt) >= lastUsedResolution {
+			_, err = config.DB.Collection("kiosks").UpdateOne(context.TODO(), bson.M{"_id": kiosk.ID}, bson.M{"$set": bson.M{"last_seen_at": &now}})
+			if err != nil {
+				abort(c, http.StatusInternalServerError, "Failed to update kiosk")
+				return
+			}
+		}
+
+		c.Set("kiosk", kiosk)
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- what each scope opens up, "METHOD /full/path" as registered in main. API keys reach nothing else
+var APIKeyScopes = map[string][]string{
+	"attendance:read": {
+		"GET /api/team-attendance",
+		"GET /api/team-attendance/export",
+		"GET /api/team-attendance/summary",
+	},
+	"users:read": {
+		"GET /api/users",
+		"GET /api/users/:id",
+		"GET /api/user-attributes",
+	},
+	"users:write": {
+		"POST /api/users",
+		"POST /api/users/import",
+		"PUT /api/users/:id",
+		"POST /api/users/:id/deactivate",
+		"POST /api/users/:id/reactivate",
+	},
+	"corrections:read": {
+		"GET /api/pending-corrections",
+		"GET /api/correction/:id",
+	},
+	"terminal-logs:write": {
+		"POST /api/terminal-logs",
+	},
+}
+
+// ------- machine credential issued by an admin. the key itself is only shown once, Prefix identifies it in lists
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	AllowedIPs []string           `bson:"allowed_ips,omitempty" json:"allowed_ips,omitempty"` // ---------------- CIDRs, empty allows any
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string             `bson:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+	CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+type APIKeyRequest struct {
+	Name       string     `json:"name" binding:"required"`
+	Scopes     []string   `json:"scopes" binding:"required"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- rules are ANDed, zero values mean "don't care"
+type ChainRules struct {
+	MinHoursChanged      float64  `bson:"min_hours_changed" json:"min_hours_changed"`
+	MinAttendanceAgeDays int      `bson:"min_attendance_age_days" json:"min_attendance_age_days"`
+	Groups               []string `bson:"groups,omitempty" json:"groups,omitempty"`
+}
+
+type ChainStep struct {
+	Name         string              `bson:"name" json:"name"`
+	ReviewerType string              `bson:"reviewer_type" json:"reviewer_type"` // ---------------- manager-role-user
+	ReviewerRole string              `bson:"reviewer_role,omitempty" json:"reviewer_role,omitempty"`
+	ReviewerID   *primitive.ObjectID `bson:"reviewer_id,omitempty" json:"reviewer_id,omitempty"`
+}
+
+type ApprovalChain struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Priority  int                `bson:"priority" json:"priority"` // ---------------- lowest matching priority wins
+	Rules     ChainRules         `bson:"rules" json:"rules"`
+	Steps     []ChainStep        `bson:"steps" json:"steps"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type ApprovalChainRequest struct {
+	Name     string      `json:"name" binding:"required"`
+	Priority int         `json:"priority"`
+	Rules    ChainRules  `json:"rules"`
+	Steps    []ChainStep `json:"steps" binding:"required"`
+	Active   *bool       `json:"active"`
+}
+
+// one per chain step, copied onto the correction when it is created so later chain edits don't change it
+type ApprovalStep struct {
+	Name          string              `bson:"name" json:"name"`
+	ReviewerType  string              `bson:"reviewer_type" json:"reviewer_type"`
+	ReviewerRole  string              `bson:"reviewer_role,omitempty" json:"reviewer_role,omitempty"`
+	ReviewerID    *primitive.ObjectID `bson:"reviewer_id,omitempty" json:"reviewer_id,omitempty"` // ---------------- resolved manager or named user
+	Decision      string              `bson:"decision" json:"decision"`                           // ---------------- waiting-pending-approved-rejected
+	DecidedBy     *primitive.ObjectID `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedAt     *time.Time          `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
+	Comment       string              `bson:"comment,omitempty" json:"comment,omitempty"`
+	EscalatedFrom *primitive.ObjectID `bson:"escalated_from,omitempty" json:"escalated_from,omitempty"`
+}
@@ -9,8 +9,29 @@ import (
 type Location struct {
 	Latitude  float64 `bson:"latitude" json:"latitude"`
 	Longitude float64 `bson:"longitude" json:"longitude"`
+
+	// ------- as reported by the device, older apps leave them out
+	Accuracy  *float64   `bson:"accuracy,omitempty" json:"accuracy,omitempty"` // ---------------- meters
+	Altitude  *float64   `bson:"altitude,omitempty" json:"altitude,omitempty"`
+	Provider  string     `bson:"provider,omitempty" json:"provider,omitempty"` // ---------------- gps-network-fused
+	Timestamp *time.Time `bson:"timestamp,omitempty" json:"timestamp,omitempty"`
+	Mock      bool       `bson:"mock,omitempty" json:"mock,omitempty"`
 }
 
+const (
+	FlagUnregisteredDevice  = "unregistered_device"
+	FlagMockLocation        = "mock_location"
+	FlagZeroAccuracy        = "zero_accuracy"
+	FlagClockSkew           = "clock_skew"
+	FlagImpossibleTravel    = "impossible_travel"
+	FlagRepeatedCoordinates = "repeated_coordinates"
+	FlagNetworkMismatch     = "network_mismatch"
+	FlagLateSync            = "late_sync"
+	FlagOutOfOrder          = "out_of_order"
+)
+
+var FlagReviewDecisions = []string{"cleared", "rejected"}
+
 type Attendance struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
@@ -23,12 +44,50 @@ type Attendance struct {
 	Status      string             `bson:"status" json:"status"` // ---------------- valid-invalid-pending
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+
+	CheckInDevice  string              `bson:"check_in_device,omitempty" json:"check_in_device,omitempty"`
+	CheckOutDevice string              `bson:"check_out_device,omitempty" json:"check_out_device,omitempty"`
+	CheckInIP      string              `bson:"check_in_ip,omitempty" json:"check_in_ip,omitempty"`
+	CheckOutIP     string              `bson:"check_out_ip,omitempty" json:"check_out_ip,omitempty"`
+	CheckInKiosk   *primitive.ObjectID `bson:"check_in_kiosk,omitempty" json:"check_in_kiosk,omitempty"`
+	CheckOutKiosk  *primitive.ObjectID `bson:"check_out_kiosk,omitempty" json:"check_out_kiosk,omitempty"`
+	Flags          []AttendanceFlag    `bson:"flags,omitempty" json:"flags,omitempty"`
+
+	// ------- set to pending whenever a flag is added, an admin clears or rejects the record
+	FlagReview string              `bson:"flag_review,omitempty" json:"flag_review,omitempty"` // ---------------- pending-cleared-rejected
+	ReviewedBy *primitive.ObjectID `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time          `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	ReviewNote string              `bson:"review_note,omitempty" json:"review_note,omitempty"`
+}
+
+// ------- something about a punch that a person should look at, the punch itself was still recorded
+type AttendanceFlag struct {
+	Type      string    `bson:"type" json:"type"`   // ---------------- one of the Flag* constants
+	Punch     string    `bson:"punch" json:"punch"` // ---------------- check_in-check_out
+	Detail    string    `bson:"detail,omitempty" json:"detail,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+type FlagReviewRequest struct {
+	Decision string `json:"decision" binding:"required"` // ---------------- cleared-rejected
+	Note     string `json:"note"`
 }
 
 type CheckInRequest struct {
 	Location Location `json:"location" binding:"required"`
+	DeviceID string   `json:"device_id"`
 }
 
 type CheckOutRequest struct {
 	Location Location `json:"location" binding:"required"`
+	DeviceID string   `json:"device_id"`
+}
+
+// ------- one row of /team-attendance/summary, Key is the value of the group_by field (nil for employees without it)
+type AttendanceGroup struct {
+	Key          any     `bson:"key" json:"key"`
+	Employees    int     `bson:"employees" json:"employees"`
+	Days         int     `bson:"days" json:"days"`
+	TotalHours   float64 `bson:"total_hours" json:"total_hours"`
+	AverageHours float64 `bson:"average_hours" json:"average_hours"`
 }
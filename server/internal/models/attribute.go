@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var AttributeTypes = []string{"string", "number", "boolean", "date", "enum"}
+
+// ------- admin defined user metadata (cost center, badge number ...), values live in User.Attributes under Key
+type AttributeDefinition struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key           string             `bson:"key" json:"key"`
+	Label         string             `bson:"label" json:"label"`
+	Type          string             `bson:"type" json:"type"` // ---------------- string-number-boolean-date-enum
+	Required      bool               `bson:"required" json:"required"`
+	AllowedValues []string           `bson:"allowed_values,omitempty" json:"allowed_values,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type AttributeDefinitionRequest struct {
+	Key           string   `json:"key" binding:"required"`
+	Label         string   `json:"label" binding:"required"`
+	Type          string   `json:"type" binding:"required"`
+	Required      bool     `json:"required"`
+	AllowedValues []string `json:"allowed_values"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditLog struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action     string             `bson:"action" json:"action"` // ---------------- correction.approved, correction.rejected ...
+	EntityType string             `bson:"entity_type" json:"entity_type"`
+	EntityID   primitive.ObjectID `bson:"entity_id" json:"entity_id"`
+	ActorID    primitive.ObjectID `bson:"actor_id" json:"actor_id"`
+	Details    map[string]any     `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
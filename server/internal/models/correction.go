@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"github.com/Sourav01112/server/internal/utils"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -13,12 +15,45 @@ type Correction struct {
 	RequestedCheckIn  *time.Time          `bson:"requested_check_in" json:"requested_check_in"`
 	RequestedCheckOut *time.Time          `bson:"requested_check_out" json:"requested_check_out"`
 	Reason            string              `bson:"reason" json:"reason"`
-	Status            string              `bson:"status" json:"status"` // ---------------- pending-approved-rejected
+	Status            string              `bson:"status" json:"status"` // ---------------- pending-needs_info-approved-rejected
 	CreatedAt         time.Time           `bson:"created_at" json:"created_at"`
 	ExpiresAt         time.Time           `bson:"expires_at" json:"expires_at"`
 	ReviewedAt        *time.Time          `bson:"reviewed_at" json:"reviewed_at"`
 	ReviewedBy        *primitive.ObjectID `bson:"reviewed_by" json:"reviewed_by"`
 	Comments          string              `bson:"comments"  json:"comments"`
+	ChainID           *primitive.ObjectID `bson:"chain_id,omitempty" json:"chain_id,omitempty"`
+	Steps             []ApprovalStep      `bson:"steps,omitempty" json:"steps,omitempty"`
+	CurrentStep       int                 `bson:"current_step" json:"current_step"`
+	AssignedTo        *primitive.ObjectID `bson:"assigned_to,omitempty" json:"assigned_to,omitempty"` // ---------------- reviewer of the current step
+	AssignedRole      string              `bson:"assigned_role,omitempty" json:"assigned_role,omitempty"`
+	StepStartedAt     *time.Time          `bson:"step_started_at,omitempty" json:"step_started_at,omitempty"`
+	RemindedAt        *time.Time          `bson:"reminded_at,omitempty" json:"reminded_at,omitempty"`
+	EscalatedAt       *time.Time          `bson:"escalated_at,omitempty" json:"escalated_at,omitempty"`
+	Thread            []ThreadMessage     `bson:"thread,omitempty" json:"thread,omitempty"`
+	Attachments       []Attachment        `bson:"attachments,omitempty" json:"attachments,omitempty"`
+}
+
+type ThreadMessage struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	AuthorID   primitive.ObjectID `bson:"author_id" json:"author_id"`
+	AuthorName string             `bson:"author_name" json:"author_name"`
+	Kind       string             `bson:"kind" json:"kind"` // ---------------- comment-info_request-info_reply
+	Body       string             `bson:"body" json:"body"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	FileName    string             `bson:"file_name" json:"file_name"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	StorageKey  string             `bson:"storage_key" json:"-"`
+	UploadedBy  primitive.ObjectID `bson:"uploaded_by" json:"uploaded_by"`
+	UploadedAt  time.Time          `bson:"uploaded_at" json:"uploaded_at"`
+}
+
+type ThreadMessageRequest struct {
+	Message string `json:"message" binding:"required"`
 }
 
 type CorrectionRequest struct {
@@ -27,3 +62,16 @@ type CorrectionRequest struct {
 	RequestedCheckOut *time.Time `json:"requested_check_out"`
 	Reason            string     `json:"reason" binding:"required"`
 }
+
+type BulkReviewRequest struct {
+	IDs      []string `json:"ids" binding:"required"`
+	Action   string   `json:"action" binding:"required"` // ---------------- approve-reject
+	Comments string   `json:"comments"`
+}
+
+type BulkReviewResult struct {
+	ID     string             `json:"id"`
+	Result string             `json:"result"` // ---------------- approved-rejected-conflict-invalid-failed
+	Error  string             `json:"error,omitempty"`
+	Errors []utils.FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- a phone or browser an employee checks in from, DeviceID is generated and kept by the app
+type Device struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	DeviceID   string              `bson:"device_id" json:"device_id"`
+	Name       string              `bson:"name" json:"name"`
+	Status     string              `bson:"status" json:"status"` // ---------------- pending-approved
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	ApprovedAt *time.Time          `bson:"approved_at,omitempty" json:"approved_at,omitempty"`
+	ApprovedBy *primitive.ObjectID `bson:"approved_by,omitempty" json:"approved_by,omitempty"`
+	LastUsedAt *time.Time          `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+type DeviceRequest struct {
+	DeviceID string `json:"device_id" binding:"required"`
+	Name     string `json:"name"`
+}
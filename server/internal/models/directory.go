@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- an on-prem LDAP directory. users whose email domain is in Domains sign in by binding against it,
+// and the sync job keeps the users of GroupRoles in step with the directory
+type Directory struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name     string             `bson:"name" json:"name"`
+	Domains  []string           `bson:"domains" json:"domains"`
+	URL      string             `bson:"url" json:"url"` // ---------------- ldap://host:389 or ldaps://host:636
+	StartTLS bool               `bson:"start_tls" json:"start_tls"`
+
+	// ------- service account for searches, the password is read from the env var named here, never stored
+	BindDN          string `bson:"bind_dn" json:"bind_dn"`
+	BindPasswordEnv string `bson:"bind_password_env" json:"bind_password_env"`
+
+	BaseDN            string `bson:"base_dn" json:"base_dn"`
+	UserFilter        string `bson:"user_filter" json:"user_filter"`
+	IDAttribute       string `bson:"id_attribute" json:"id_attribute"`
+	EmailAttribute    string `bson:"email_attribute" json:"email_attribute"`
+	NameAttribute     string `bson:"name_attribute" json:"name_attribute"`
+	MemberOfAttribute string `bson:"member_of_attribute" json:"member_of_attribute"`
+
+	GroupRoles  []GroupRole `bson:"group_roles" json:"group_roles"` // ---------------- first matching group wins
+	SyncEnabled bool        `bson:"sync_enabled" json:"sync_enabled"`
+	Active      bool        `bson:"active" json:"active"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type GroupRole struct {
+	GroupDN string `bson:"group_dn" json:"group_dn"`
+	Role    string `bson:"role" json:"role"`
+}
+
+type DirectoryRequest struct {
+	Name              string      `json:"name" binding:"required"`
+	Domains           []string    `json:"domains"`
+	URL               string      `json:"url" binding:"required"`
+	StartTLS          bool        `json:"start_tls"`
+	BindDN            string      `json:"bind_dn"`
+	BindPasswordEnv   string      `json:"bind_password_env"`
+	BaseDN            string      `json:"base_dn" binding:"required"`
+	UserFilter        string      `json:"user_filter"`
+	IDAttribute       string      `json:"id_attribute"`
+	EmailAttribute    string      `json:"email_attribute"`
+	NameAttribute     string      `json:"name_attribute"`
+	MemberOfAttribute string      `json:"member_of_attribute"`
+	GroupRoles        []GroupRole `json:"group_roles"`
+	SyncEnabled       bool        `json:"sync_enabled"`
+	Active            *bool       `json:"active"`
+}
+
+// ------- one run of the directory sync, written even for dry runs so admins can review before enabling
+type SyncReport struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DirectoryID   primitive.ObjectID `bson:"directory_id" json:"directory_id"`
+	DirectoryName string             `bson:"directory_name" json:"directory_name"`
+	Trigger       string             `bson:"trigger" json:"trigger"` // ---------------- schedule-manual
+	DryRun        bool               `bson:"dry_run" json:"dry_run"`
+	Status        string             `bson:"status" json:"status"` // ---------------- completed-failed
+	Found         int                `bson:"found" json:"found"`
+	Created       []SyncChange       `bson:"created" json:"created"`
+	Updated       []SyncChange       `bson:"updated" json:"updated"`
+	Deactivated   []SyncChange       `bson:"deactivated" json:"deactivated"`
+	Skipped       []SyncChange       `bson:"skipped" json:"skipped"`
+	Errors        []string           `bson:"errors" json:"errors"`
+	StartedAt     time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt    time.Time          `bson:"finished_at" json:"finished_at"`
+}
+
+type SyncChange struct {
+	UserID  *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Email   string              `bson:"email" json:"email"`
+	Changes map[string]any      `bson:"changes,omitempty" json:"changes,omitempty"`
+	Reason  string              `bson:"reason,omitempty" json:"reason,omitempty"`
+}
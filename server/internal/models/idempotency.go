@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- a punch request sent with an Idempotency-Key header and the response it got, replayed when the
+// same key comes again. CompletedAt is nil while the first request is still running
+type IdempotencyKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Scope       string             `bson:"scope" json:"scope"` // ---------------- user or kiosk the key belongs to
+	Key         string             `bson:"key" json:"key"`
+	RequestHash string             `bson:"request_hash" json:"request_hash"`
+	Status      int                `bson:"status,omitempty" json:"status,omitempty"`
+	ContentType string             `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	Body        string             `bson:"body,omitempty" json:"body,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Sourav01112/server/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- only the sha256 of the token is stored, the token itself only goes out in the email
+type Invitation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Email     string             `bson:"email" json:"email"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	CreatedBy primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ImportRowResult struct {
+	Row    int                `json:"row"`
+	Email  string             `json:"email"`
+	Result string             `json:"result"` // ---------------- valid-imported-invalid-failed
+	UserID string             `json:"user_id,omitempty"`
+	Invite string             `json:"invite,omitempty"` // ---------------- sent-failed
+	Errors []utils.FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- a shared tablet at a site. it authenticates with its own token, punches made through it
+// get its fixed location
+type Kiosk struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Site       string             `bson:"site,omitempty" json:"site,omitempty"` // ---------------- only users of this site, empty for anyone
+	Location   Location           `bson:"location" json:"location"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	Active     bool               `bson:"active" json:"active"`
+	LastSeenAt *time.Time         `bson:"last_seen_at,omitempty" json:"last_seen_at,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// ------- wrong PINs or unknown codes typed here since PINFailuresSince, throttles guessing across employees
+	PINFailures      int        `bson:"pin_failures,omitempty" json:"-"`
+	PINFailuresSince *time.Time `bson:"pin_failures_since,omitempty" json:"-"`
+}
+
+type KioskRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Site     string   `json:"site"`
+	Location Location `json:"location" binding:"required"`
+	Active   *bool    `json:"active"`
+}
+
+// ------- what the kiosk shows as a QR code, Code is only valid for KIOSK_QR_TTL
+type KioskQR struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ------- sent by the employee's phone after scanning
+type KioskScanRequest struct {
+	Code     string `json:"code" binding:"required"`
+	Action   string `json:"action" binding:"required"` // ---------------- check_in-check_out
+	DeviceID string `json:"device_id"`
+}
+
+// ------- typed on the kiosk by employees without a phone
+type KioskPINRequest struct {
+	EmployeeCode string `json:"employee_code" binding:"required"`
+	PIN          string `json:"pin" binding:"required"`
+	Action       string `json:"action" binding:"required"` // ---------------- check_in-check_out
+}
+
+// ------- bcrypt hash of the employee's kiosk PIN, locked for a while after repeated wrong PINs
+type KioskPIN struct {
+	Hash           string     `bson:"hash,omitempty" json:"-"`
+	FailedAttempts int        `bson:"failed_attempts,omitempty" json:"-"`
+	LockedUntil    *time.Time `bson:"locked_until,omitempty" json:"-"`
+}
+
+type SetKioskPINRequest struct {
+	PIN string `json:"pin" binding:"required"`
+}
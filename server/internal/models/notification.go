@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- in-app notifications, either for one user or for everyone with a role
+type Notification struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID       *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Role         string              `bson:"role,omitempty" json:"role,omitempty"`
+	Type         string              `bson:"type" json:"type"` // ---------------- correction_reminder-correction_escalated
+	Message      string              `bson:"message" json:"message"`
+	CorrectionID *primitive.ObjectID `bson:"correction_id,omitempty" json:"correction_id,omitempty"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
+
+type SLABreach struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ReviewerID   *primitive.ObjectID `bson:"reviewer_id,omitempty" json:"reviewer_id,omitempty"`
+	ReviewerRole string              `bson:"reviewer_role,omitempty" json:"reviewer_role,omitempty"`
+	CorrectionID primitive.ObjectID  `bson:"correction_id" json:"correction_id"`
+	Step         int                 `bson:"step" json:"step"`
+	Kind         string              `bson:"kind" json:"kind"` // ---------------- reminder-escalation
+	WaitingHours float64             `bson:"waiting_hours" json:"waiting_hours"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- a punch the app queued while offline. ClientID is generated on the device once per punch,
+// sending it again returns the first result
+type OfflinePunch struct {
+	ClientID  string    `json:"client_id"`
+	Action    string    `json:"action"` // ---------------- check_in-check_out
+	Timestamp time.Time `json:"timestamp"`
+	Location  Location  `json:"location"`
+	DeviceID  string    `json:"device_id"`
+}
+
+type PunchSyncRequest struct {
+	SentAt  *time.Time     `json:"sent_at"` // ---------------- device clock when the batch was sent, to spot a wrong clock
+	Punches []OfflinePunch `json:"punches" binding:"required"`
+}
+
+type PunchSyncResult struct {
+	ClientID string           `bson:"client_id" json:"client_id"`
+	Status   string           `bson:"status" json:"status"` // ---------------- applied-duplicate-rejected
+	Error    string           `bson:"error,omitempty" json:"error,omitempty"`
+	Date     string           `bson:"date,omitempty" json:"date,omitempty"`
+	Flags    []AttendanceFlag `bson:"flags,omitempty" json:"flags,omitempty"`
+}
+
+// ------- the settled result of an offline punch, kept so a resent batch gets the same answer and never punches twice
+type SyncedPunch struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ClientID  string             `bson:"client_id" json:"client_id"`
+	Action    string             `bson:"action" json:"action"`
+	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+	Result    PunchSyncResult    `bson:"result" json:"result"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
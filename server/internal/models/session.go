@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- one login. the token carries the session id as "sid", AuthMiddleware refuses it once revoked
+type Session struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Method     string              `bson:"method" json:"method"` // ---------------- password, ldap, oidc
+	Device     string              `bson:"device" json:"device"`
+	UserAgent  string              `bson:"user_agent" json:"user_agent"`
+	IP         string              `bson:"ip" json:"ip"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	LastSeenAt time.Time           `bson:"last_seen_at" json:"last_seen_at"`
+	LastSeenIP string              `bson:"last_seen_ip" json:"last_seen_ip"`
+	ExpiresAt  time.Time           `bson:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedBy  *primitive.ObjectID `bson:"revoked_by,omitempty" json:"revoked_by,omitempty"`
+	Current    bool                `bson:"-" json:"current"`
+}
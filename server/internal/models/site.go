@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var NetworkPolicies = []string{"reject", "flag"}
+
+// ------- an office, matched to users by User.Site. with AllowedNetworks set, punches of its users (or only of
+// NetworkRoles) must come from those ranges
+type Site struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	AllowedNetworks []string           `bson:"allowed_networks" json:"allowed_networks"` // ---------------- CIDRs
+	NetworkPolicy   string             `bson:"network_policy" json:"network_policy"`     // ---------------- reject-flag
+	NetworkRoles    []string           `bson:"network_roles,omitempty" json:"network_roles,omitempty"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type SiteRequest struct {
+	Name            string   `json:"name" binding:"required"`
+	AllowedNetworks []string `json:"allowed_networks"`
+	NetworkPolicy   string   `json:"network_policy"`
+	NetworkRoles    []string `json:"network_roles"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- one pending authorization-code login, consumed by the callback
+type OIDCLogin struct {
+	State     string    `bson:"state"`
+	Nonce     string    `bson:"nonce"`
+	Verifier  string    `bson:"verifier"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// ------- a user as an external directory or identity provider describes them
+type ExternalIdentity struct {
+	Provider    string
+	ExternalID  string
+	DirectoryID *primitive.ObjectID // ---------------- ldap only, ids are unique per directory
+	Email       string
+	Name        string
+	Role        string
+
+	EmailVerified bool // ---------------- required to link an existing account by email
+	RoleManaged   bool // ---------------- the provider decides the role, no Role falls back to SSO_DEFAULT_ROLE
+}
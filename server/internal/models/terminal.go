@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- result of merging one terminal log, nothing is written on a dry run
+type TerminalIngestReport struct {
+	Terminal  string              `json:"terminal"`
+	DryRun    bool                `json:"dry_run"`
+	Punches   int                 `json:"punches"`
+	Invalid   []TerminalLineError `json:"invalid"`
+	Unmatched []UnmatchedCode     `json:"unmatched"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Conflicts []TerminalConflict  `json:"conflicts"`
+	Skipped   []SkippedDay        `json:"skipped"`
+}
+
+type TerminalLineError struct {
+	Line  int    `json:"line"`
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+// ------- a terminal user code no employee has, or several have, with how many punches were skipped for it
+type UnmatchedCode struct {
+	Code    string `json:"code"`
+	Punches int    `json:"punches"`
+	Reason  string `json:"reason,omitempty"` // ---------------- set when the code matches more than one user
+}
+
+// ------- punches of an employee who can't have attendance that day (inactive, or outside the employment dates),
+// or only a check-out for a day without a check-in
+type SkippedDay struct {
+	UserID primitive.ObjectID `json:"user_id"`
+	Code   string             `json:"code"`
+	Date   string             `json:"date"`
+	Reason string             `json:"reason"`
+}
+
+// ------- the record already has a different time for the field, it is left alone
+type TerminalConflict struct {
+	UserID   primitive.ObjectID `json:"user_id"`
+	Code     string             `json:"code"`
+	Date     string             `json:"date"`
+	Field    string             `json:"field"` // ---------------- check_in-check_out
+	Existing time.Time          `json:"existing"`
+	Terminal time.Time          `json:"terminal"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// ------- TOTP state on the user. secrets and recovery code hashes never leave the server
+type TwoFactor struct {
+	Enabled   bool       `bson:"enabled" json:"enabled"`
+	Required  bool       `bson:"required" json:"required"` // ---------------- set by an admin, on top of TWO_FACTOR_REQUIRED_ROLES
+	EnabledAt *time.Time `bson:"enabled_at,omitempty" json:"enabled_at,omitempty"`
+
+	Secret         string     `bson:"secret,omitempty" json:"-"`
+	PendingSecret  string     `bson:"pending_secret,omitempty" json:"-"`
+	RecoveryCodes  []string   `bson:"recovery_codes,omitempty" json:"-"` // ---------------- sha256 hex, each removed when used
+	LastStep       int64      `bson:"last_step,omitempty" json:"-"`
+	FailedAttempts int        `bson:"failed_attempts,omitempty" json:"-"`
+	LockedUntil    *time.Time `bson:"locked_until,omitempty" json:"-"`
+}
+
+// ------- what login returns instead of a token when a second factor is needed
+type TwoFactorChallenge struct {
+	MFAToken      string `json:"mfa_token"`
+	SetupRequired bool   `json:"setup_required"`
+}
+
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// ------- either the authenticator code or one of the recovery codes
+type TwoFactorVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorRequirementRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
@@ -6,13 +6,63 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleEmployee = "employee"
+	RoleManager  = "manager"
+	RoleHR       = "hr"
+	RoleAdmin    = "admin"
+)
+
+var Roles = []string{RoleEmployee, RoleManager, RoleHR, RoleAdmin}
+
+// ------- roles an API key can neither grant nor change, a scoped key mustn't be a way to mint admins
+var PrivilegedRoles = []string{RoleHR, RoleAdmin}
+
+var EmploymentTypes = []string{"full_time", "part_time", "contract", "intern"}
+
+// ----------- HR details used by reports, dates are "2006-01-02" like attendance dates
+type EmployeeProfile struct {
+	EmployeeCode   string `bson:"employee_code,omitempty" json:"employee_code,omitempty"`
+	Department     string `bson:"department,omitempty" json:"department,omitempty"`
+	JobTitle       string `bson:"job_title,omitempty" json:"job_title,omitempty"`
+	EmploymentType string `bson:"employment_type,omitempty" json:"employment_type,omitempty"`
+	JoiningDate    string `bson:"joining_date,omitempty" json:"joining_date,omitempty"`
+	ExitDate       string `bson:"exit_date,omitempty" json:"exit_date,omitempty"`
+}
+
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Email     string             `bson:"email" json:"email"`
-	Password  string             `bson:"password" json:"-"`
-	Name      string             `bson:"name" json:"name"`
-	Role      string             `bson:"role" json:"role"` // ----------- employee-admin
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Email         string              `bson:"email" json:"email"`
+	Password      string              `bson:"password" json:"-"`
+	Name          string              `bson:"name" json:"name"`
+	Role          string              `bson:"role" json:"role"` // ----------- employee-manager-hr-admin
+	ManagerID     *primitive.ObjectID `bson:"manager_id,omitempty" json:"manager_id,omitempty"`
+	Group         string              `bson:"group,omitempty" json:"group,omitempty"`
+	Site          string              `bson:"site,omitempty" json:"site,omitempty"`
+	Shift         string              `bson:"shift,omitempty" json:"shift,omitempty"` // ----------- "09:00-18:00"
+	Timezone      string              `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Status        string              `bson:"status,omitempty" json:"status,omitempty"` // ----------- invited-active-deactivated, empty on older users means active
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt     *time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	DeactivatedAt *time.Time          `bson:"deactivated_at,omitempty" json:"deactivated_at,omitempty"`
+	DeactivatedBy string              `bson:"deactivated_by,omitempty" json:"deactivated_by,omitempty"` // ---------------- "directory_sync" when the sync did it
+
+	EmployeeProfile `bson:",inline"`
+
+	Attributes map[string]any `bson:"attributes,omitempty" json:"attributes,omitempty"`
+
+	TwoFactor TwoFactor `bson:"two_factor" json:"two_factor"`
+
+	KioskPIN KioskPIN `bson:"kiosk_pin,omitempty" json:"-"`
+
+	DeviceCount int `bson:"device_count,omitempty" json:"-"` // ---------------- registered devices, held under DEVICE_LIMIT
+
+	// ------- empty for password accounts, "oidc" or "ldap" for users signing in elsewhere
+	AuthProvider string              `bson:"auth_provider,omitempty" json:"auth_provider,omitempty"`
+	ExternalID   string              `bson:"external_id,omitempty" json:"-"`
+	DirectoryID  *primitive.ObjectID `bson:"directory_id,omitempty" json:"directory_id,omitempty"`
+
+	APIKey bool `bson:"-" json:"-"` // ---------------- set on the stand-in user of an API key request, never stored
 }
 
 type LoginRequest struct {
@@ -23,4 +73,39 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
+
+	// only when 2FA was just set up during login, shown once
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type UpdateUserRequest struct {
+	Email     *string `json:"email"`
+	Name      *string `json:"name"`
+	Role      *string `json:"role"`
+	ManagerID *string `json:"manager_id"`
+	Group     *string `json:"group"`
+	Site      *string `json:"site"`
+	Shift     *string `json:"shift"`
+	Timezone  *string `json:"timezone"`
+
+	EmployeeCode   *string `json:"employee_code"`
+	Department     *string `json:"department"`
+	JobTitle       *string `json:"job_title"`
+	EmploymentType *string `json:"employment_type"`
+	JoiningDate    *string `json:"joining_date"`
+	ExitDate       *string `json:"exit_date"`
+
+	// ------- only the keys sent are changed, null removes one
+	Attributes map[string]any `json:"attributes"`
+}
+
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type UserListResponse struct {
+	Users []User `json:"users"`
+	Total int64  `json:"total"`
+	Page  int64  `json:"page"`
+	Limit int64  `json:"limit"`
 }
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/directory"
+	"github.com/Sourav01112/server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const deactivatedBySync = "directory_sync"
+
+// ------- runs on LDAP_SYNC_SCHEDULE for every active directory with sync_enabled
+func syncDirectories() {
+	cursor, err := config.DB.Collection("directories").Find(context.TODO(), bson.M{"active": true, "sync_enabled": true})
+	if err != nil {
+		log.Printf("Error loading directories: %v", err)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	var dirs []models.Directory
+	if err = cursor.All(context.TODO(), &dirs); err != nil {
+		log.Printf("Error decoding directories: %v", err)
+		return
+	}
+
+	for _, dir := range dirs {
+		report := SyncDirectory(context.TODO(), dir, "schedule", false)
+		log.Printf("Directory %s synced: %s, %d found, %d created, %d updated, %d deactivated",
+			dir.Name, report.Status, report.Found, len(report.Created), len(report.Updated), len(report.Deactivated))
+	}
+}
+
+// ------- creates users in the mapped groups, updates name, email and role, and deactivates this directory's
+// users that left the groups. accounts deactivated by an admin stay deactivated. the report is always stored
+func SyncDirectory(ctx context.Context, dir models.Directory, trigger string, dryRun bool) models.SyncReport {
+	report := models.SyncReport{
+		DirectoryID:   dir.ID,
+		DirectoryName: dir.Name,
+		Trigger:       trigger,
+		DryRun:        dryRun,
+		Status:        "completed",
+		Created:       []models.SyncChange{},
+		Updated:       []models.SyncChange{},
+		Deactivated:   []models.SyncChange{},
+		Skipped:       []models.SyncChange{},
+		Errors:        []string{},
+		StartedAt:     time.Now(),
+	}
+
+	if err := syncMembers(ctx, dir, &report); err != nil {
+		report.Status = "failed"
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	report.FinishedAt = time.Now()
+	result, err := config.DB.Collection("directory_sync_reports").InsertOne(ctx, report)
+	if err != nil {
+		log.Printf("Error saving sync report for %s: %v", dir.Name, err)
+	} else {
+		report.ID = result.InsertedID.(primitive.ObjectID)
+	}
+
+	return report
+}
+
+func syncMembers(ctx context.Context, dir models.Directory, report *models.SyncReport) error {
+	members, err := directory.Members(dir)
+	if err != nil {
+		return err
+	}
+	report.Found = len(members)
+
+	cursor, err := config.DB.Collection("users").Find(ctx, bson.M{"directory_id": dir.ID})
+	if err != nil {
+		return err
+	}
+	var linked []models.User
+	if err = cursor.All(ctx, &linked); err != nil {
+		return err
+	}
+
+	byExternalID := map[string]models.User{}
+	for _, u := range linked {
+		byExternalID[u.ExternalID] = u
+	}
+
+	seen := map[primitive.ObjectID]bool{}
+	for _, entry := range members {
+		if entry.ID == "" || entry.Email == "" {
+			report.Skipped = append(report.Skipped, models.SyncChange{Email: entry.Email, Reason: "entry " + entry.DN + " has no id or email"})
+			continue
+		}
+
+		role, err := directory.RoleFor(dir, entry)
+		if err != nil {
+			report.Skipped = append(report.Skipped, models.SyncChange{Email: entry.Email, Reason: err.Error()})
+			continue
+		}
+
+		user, ok := byExternalID[entry.ID]
+		if !ok {
+			// ------- a password account of an ordinary user with the same email is taken over by the directory.
+			// one that signs in through SSO or another directory, or holds a privileged role, an admin links
+			err := config.DB.Collection("users").FindOne(ctx, bson.M{"email": entry.Email}).Decode(&user)
+			if err != nil && err != mongo.ErrNoDocuments {
+				report.Errors = append(report.Errors, entry.Email+": "+err.Error())
+				continue
+			}
+			if err == nil && (user.AuthProvider != "" || slices.Contains(models.PrivilegedRoles, user.Role)) {
+				report.Skipped = append(report.Skipped, models.SyncChange{UserID: &user.ID, Email: user.Email, Reason: "needs manual linking"})
+				continue
+			}
+			ok = err == nil
+		}
+
+		if !ok {
+			if err := createSyncedUser(ctx, dir, entry, role, report); err != nil {
+				report.Errors = append(report.Errors, entry.Email+": "+err.Error())
+			}
+			continue
+		}
+
+		seen[user.ID] = true
+		if err := updateSyncedUser(ctx, dir, user, entry, role, report); err != nil {
+			report.Errors = append(report.Errors, entry.Email+": "+err.Error())
+		}
+	}
+
+	// ------- an empty result is far more likely a broken filter than everyone leaving
+	if len(members) == 0 && len(linked) > 0 {
+		report.Errors = append(report.Errors, "directory returned no members, nobody was deactivated")
+		return nil
+	}
+
+	for _, user := range linked {
+		if seen[user.ID] || user.Status == "deactivated" {
+			continue
+		}
+
+		change := models.SyncChange{UserID: &user.ID, Email: user.Email, Reason: "no longer in a mapped group"}
+		if !report.DryRun {
+			now := time.Now()
+			_, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+				"status":         "deactivated",
+				"deactivated_at": &now,
+				"deactivated_by": deactivatedBySync,
+				"updated_at":     &now,
+			}})
+			if err != nil {
+				report.Errors = append(report.Errors, user.Email+": "+err.Error())
+				continue
+			}
+		}
+		report.Deactivated = append(report.Deactivated, change)
+	}
+
+	return nil
+}
+
+func createSyncedUser(ctx context.Context, dir models.Directory, entry directory.Entry, role string, report *models.SyncReport) error {
+	user := models.User{
+		ID:           primitive.NewObjectID(),
+		Email:        entry.Email,
+		Name:         entry.Name,
+		Role:         role,
+		Status:       "active",
+		AuthProvider: "ldap",
+		ExternalID:   entry.ID,
+		DirectoryID:  &dir.ID,
+		CreatedAt:    time.Now(),
+	}
+	if user.Name == "" {
+		user.Name = entry.Email
+	}
+
+	if !report.DryRun {
+		if _, err := config.DB.Collection("users").InsertOne(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	report.Created = append(report.Created, models.SyncChange{
+		UserID:  &user.ID,
+		Email:   user.Email,
+		Changes: map[string]any{"name": user.Name, "role": role},
+	})
+	return nil
+}
+
+func updateSyncedUser(ctx context.Context, dir models.Directory, user models.User, entry directory.Entry, role string, report *models.SyncReport) error {
+	changes := map[string]any{}
+	if user.Email != entry.Email {
+		changes["email"] = entry.Email
+	}
+	if entry.Name != "" && user.Name != entry.Name {
+		changes["name"] = entry.Name
+	}
+	if user.Role != role {
+		changes["role"] = role
+	}
+	if user.AuthProvider != "ldap" || user.ExternalID != entry.ID || user.DirectoryID == nil || *user.DirectoryID != dir.ID {
+		changes["auth_provider"] = "ldap"
+		changes["external_id"] = entry.ID
+		changes["directory_id"] = dir.ID
+	}
+
+	unset := bson.M{}
+	switch {
+	case user.Status == "deactivated" && user.DeactivatedBy == deactivatedBySync:
+		changes["status"] = "active"
+		unset["deactivated_at"] = ""
+		unset["deactivated_by"] = ""
+	case user.Status == "deactivated":
+		report.Skipped = append(report.Skipped, models.SyncChange{UserID: &user.ID, Email: user.Email, Reason: "deactivated by an admin"})
+		return nil
+	case user.Status == "invited":
+		changes["status"] = "active"
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if !report.DryRun {
+		set := bson.M{"updated_at": time.Now()}
+		for key, value := range changes {
+			set[key] = value
+		}
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if _, err := config.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+			return err
+		}
+	}
+
+	report.Updated = append(report.Updated, models.SyncChange{UserID: &user.ID, Email: user.Email, Changes: changes})
+	return nil
+}
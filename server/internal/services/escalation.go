@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Sourav01112/server/internal/config"
+	"github.com/Sourav01112/server/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ------- CORRECTION_REMIND_AFTER / CORRECTION_ESCALATE_AFTER, escalation counts from the reminder
+func remindStaleCorrections() {
+	remindAfter := config.DurationFromEnv("CORRECTION_REMIND_AFTER", 24*time.Hour)
+	escalateAfter := config.DurationFromEnv("CORRECTION_ESCALATE_AFTER", 24*time.Hour)
+
+	now := time.Now()
+	remindCutoff := now.Add(-remindAfter)
+
+	// ------- corrections from before step tracking only have created_at
+	filter := bson.M{
+		"status":      "pending",
+		"reminded_at": nil,
+		"$or": bson.A{
+			bson.M{"step_started_at": bson.M{"$lte": remindCutoff}},
+			bson.M{"step_started_at": nil, "created_at": bson.M{"$lte": remindCutoff}},
+		},
+	}
+
+	for _, correction := range findCorrections(filter) {
+		remindReviewer(correction, now)
+	}
+
+	filter = bson.M{
+		"status":       "pending",
+		"escalated_at": nil,
+		"reminded_at":  bson.M{"$lte": now.Add(-escalateAfter)},
+	}
+
+	for _, correction := range findCorrections(filter) {
+		escalateCorrection(correction, now)
+	}
+}
+
+func findCorrections(filter bson.M) []models.Correction {
+	cursor, err := config.DB.Collection("corrections").Find(context.TODO(), filter)
+	if err != nil {
+		log.Printf("Error fetching stale corrections: %v", err)
+		return nil
+	}
+	defer cursor.Close(context.TODO())
+
+	var corrections []models.Correction
+	if err = cursor.All(context.TODO(), &corrections); err != nil {
+		log.Printf("Error decoding stale corrections: %v", err)
+		return nil
+	}
+	return corrections
+}
+
+func remindReviewer(correction models.Correction, now time.Time) {
+	result, err := config.DB.Collection("corrections").UpdateOne(
+		context.TODO(),
+		bson.M{"_id": correction.ID, "status": "pending", "reminded_at": nil},
+		bson.M{"$set": bson.M{"reminded_at": &now}},
+	)
+	if err != nil {
+		log.Printf("Error marking correction %s reminded: %v", correction.ID.Hex(), err)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		return
+	}
+
+	assignedTo, assignedRole := currentAssignee(correction)
+
+	notify(models.Notification{
+		UserID:       assignedTo,
+		Role:         assignedRole,
+		Type:         "correction_reminder",
+		Message:      "A correction request is waiting for your review",
+		CorrectionID: &correction.ID,
+		CreatedAt:    now,
+	})
+
+	recordBreach(correction, assignedTo, assignedRole, "reminder", now)
+}
+
+// ------- hands the step to the reviewer's own manager, or to the admins when there is nobody above
+func escalateCorrection(correction models.Correction, now time.Time) {
+	assignedTo, assignedRole := currentAssignee(correction)
+
+	var nextTo *primitive.ObjectID
+	nextRole := "admin"
+
+	if assignedTo != nil {
+		var reviewer models.User
+		err := config.DB.Collection("users").FindOne(context.TODO(), bson.M{"_id": assignedTo}).Decode(&reviewer)
+		if err == nil && reviewer.ManagerID != nil && *reviewer.ManagerID != correction.UserID {
+			nextTo = reviewer.ManagerID
+			nextRole = ""
+		}
+	}
+
+	filter := bson.M{"_id": correction.ID, "status": "pending", "escalated_at": nil}
+	set := bson.M{
+		"escalated_at":  &now,
+		"assigned_to":   nextTo,
+		"assigned_role": nextRole,
+	}
+
+	if len(correction.Steps) > 0 {
+		step := correction.CurrentStep
+		filter["current_step"] = step
+
+		prefix := "steps." + strconv.Itoa(step) + "."
+		set[prefix+"reviewer_id"] = nextTo
+		set[prefix+"reviewer_role"] = nextRole
+		set[prefix+"escalated_from"] = assignedTo
+	}
+
+	result, err := config.DB.Collection("corrections").UpdateOne(context.TODO(), filter, bson.M{"$set": set})
+	if err != nil {
+		log.Printf("Error escalating correction %s: %v", correction.ID.Hex(), err)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		return
+	}
+
+	notify(models.Notification{
+		UserID:       nextTo,
+		Role:         nextRole,
+		Type:         "correction_escalated",
+		Message:      "An overdue correction request was escalated to you",
+		CorrectionID: &correction.ID,
+		CreatedAt:    now,
+	})
+
+	recordBreach(correction, assignedTo, assignedRole, "escalation", now)
+	log.Printf("Escalated correction %s", correction.ID.Hex())
+}
+
+func currentAssignee(correction models.Correction) (*primitive.ObjectID, string) {
+	if correction.AssignedTo == nil && correction.AssignedRole == "" {
+		return nil, "admin"
+	}
+	return correction.AssignedTo, correction.AssignedRole
+}
+
+func notify(notification models.Notification) {
+	if _, err := config.DB.Collection("notifications").InsertOne(context.TODO(), notification); err != nil {
+		log.Printf("Error saving notification: %v", err)
+	}
+}
+
+func recordBreach(correction models.Correction, reviewerID *primitive.ObjectID, reviewerRole string, kind string, now time.Time) {
+	started := correction.CreatedAt
+	if correction.StepStartedAt != nil {
+		started = *correction.StepStartedAt
+	}
+
+	breach := models.SLABreach{
+		ReviewerID:   reviewerID,
+		ReviewerRole: reviewerRole,
+		CorrectionID: correction.ID,
+		Step:         correction.CurrentStep,
+		Kind:         kind,
+		WaitingHours: now.Sub(started).Hours(),
+		CreatedAt:    now,
+	}
+
+	if _, err := config.DB.Collection("sla_breaches").InsertOne(context.TODO(), breach); err != nil {
+		log.Printf("Error recording SLA breach: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"log"
+	"os"
 	"time"
 
 	"github.com/Sourav01112/server/internal/config"
@@ -16,6 +17,16 @@ func StartScheduler() {
 
 	// c.AddFunc("0 * * * *", checkInvalidEntries)
 	c.AddFunc("*/1 * * * *", checkInvalidEntries)
+	c.AddFunc("*/15 * * * *", remindStaleCorrections)
+
+	// ------- LDAP_SYNC_SCHEDULE is any cron spec, "@every 6h" by default
+	syncSchedule := os.Getenv("LDAP_SYNC_SCHEDULE")
+	if syncSchedule == "" {
+		syncSchedule = "@every 6h"
+	}
+	if _, err := c.AddFunc(syncSchedule, syncDirectories); err != nil {
+		log.Printf("Invalid LDAP_SYNC_SCHEDULE %q: %v", syncSchedule, err)
+	}
 
 	c.Start()
 	log.Println("Scheduler will run every hr")
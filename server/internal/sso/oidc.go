@@ -0,0 +1,195 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrOIDCDisabled = errors.New("OIDC is not configured")
+
+// ------- what the server needs from the ID token after the claim mapping
+type Identity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Name    string
+	Role    string // ---------------- empty when OIDC_ROLE_CLAIM is unset or nothing in OIDC_ROLE_MAP matched
+	Nonce   string
+
+	RoleClaimed bool // ---------------- OIDC_ROLE_CLAIM is set, an empty Role means the user lost every mapped role
+
+	EmailVerified bool // ---------------- email_verified was true, a missing claim counts as unverified
+}
+
+type OIDCProvider struct {
+	verifier *oidc.IDTokenVerifier
+	oauth    oauth2.Config
+	issuer   string
+
+	emailClaim string
+	nameClaim  string
+	roleClaim  string
+	roleMap    map[string]string
+}
+
+var (
+	mu       sync.Mutex
+	provider *OIDCProvider
+)
+
+func OIDCEnabled() bool {
+	return os.Getenv("OIDC_ISSUER") != "" && os.Getenv("OIDC_CLIENT_ID") != ""
+}
+
+// ------- discovery runs on first use and is cached, a failed discovery is retried on the next login
+// instead of keeping the server from starting while the IdP is down
+func OIDC(ctx context.Context) (*OIDCProvider, error) {
+	if !OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if provider != nil {
+		return provider, nil
+	}
+
+	issuer := os.Getenv("OIDC_ISSUER")
+	discovered, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery for %s: %w", issuer, err)
+	}
+
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	provider = &OIDCProvider{
+		verifier: discovered.Verifier(&oidc.Config{ClientID: clientID}),
+		oauth: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       scopes,
+		},
+		issuer:     issuer,
+		emailClaim: envOr("OIDC_EMAIL_CLAIM", "email"),
+		nameClaim:  envOr("OIDC_NAME_CLAIM", "name"),
+		roleClaim:  os.Getenv("OIDC_ROLE_CLAIM"),
+		roleMap:    parseRoleMap(os.Getenv("OIDC_ROLE_MAP")),
+	}
+
+	return provider, nil
+}
+
+// authorization-code with PKCE, state and nonce are checked by the caller on the way back
+func (p *OIDCProvider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, verifier string) (Identity, error) {
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("code exchange: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("id_token: %w", err)
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("id_token claims: %w", err)
+	}
+
+	// ------- an address the IdP says it hasn't verified is not good enough to match an existing account
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return Identity{}, errors.New("email is not verified by the identity provider")
+	}
+
+	identity := Identity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   strings.ToLower(claimString(claims, p.emailClaim)),
+		Name:    claimString(claims, p.nameClaim),
+		Nonce:   idToken.Nonce,
+
+		EmailVerified: claims["email_verified"] == true,
+	}
+	if identity.Email == "" {
+		return Identity{}, fmt.Errorf("id_token has no %s claim", p.emailClaim)
+	}
+	if p.roleClaim != "" {
+		identity.Role = p.mapRole(claims[p.roleClaim])
+		identity.RoleClaimed = true
+	}
+
+	return identity, nil
+}
+
+// ------- the claim can be a string or a list (groups), the first value found in OIDC_ROLE_MAP wins.
+// without a map the claim value is used as the role as is
+func (p *OIDCProvider) mapRole(claim any) string {
+	var values []string
+	switch v := claim.(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	for _, value := range values {
+		if len(p.roleMap) == 0 {
+			return value
+		}
+		if role, ok := p.roleMap[value]; ok {
+			return role
+		}
+	}
+	return ""
+}
+
+// "attendance-admins:admin,hr-team:hr"
+func parseRoleMap(raw string) map[string]string {
+	roles := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		value, role, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if ok && value != "" && role != "" {
+			roles[value] = role
+		}
+	}
+	return roles
+}
+
+func claimString(claims map[string]any, key string) string {
+	value, _ := claims[key].(string)
+	return strings.TrimSpace(value)
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
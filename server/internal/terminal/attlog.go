@@ -0,0 +1,108 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Sourav01112/server/internal/models"
+)
+
+// ------- one line of a terminal log. Direction is "in", "out" or "" when the terminal doesn't say
+type Punch struct {
+	Line      int
+	Code      string
+	At        time.Time
+	Direction string
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04",
+}
+
+// ------- ZKTeco style attlog: user code, "2006-01-02 15:04:05" and status columns, tab separated.
+// comma separated exports and runs of spaces work too. directionColumn is 1-based, 0 ignores the direction.
+// times are read in loc, the terminal's clock
+func ParseAttlog(r io.Reader, loc *time.Location, directionColumn int) ([]Punch, []models.TerminalLineError, error) {
+	var punches []Punch
+	var lineErrors []models.TerminalLineError
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields, err := splitLine(text)
+		if err != nil || len(fields) < 2 {
+			lineErrors = append(lineErrors, models.TerminalLineError{Line: line, Text: text, Error: "expected a user code and a timestamp"})
+			continue
+		}
+
+		code := strings.TrimSpace(fields[0])
+		at, err := parseTime(fields[1], loc)
+		if code == "" || err != nil {
+			lineErrors = append(lineErrors, models.TerminalLineError{Line: line, Text: text, Error: "invalid user code or timestamp"})
+			continue
+		}
+
+		direction := ""
+		if directionColumn > 0 && directionColumn <= len(fields) {
+			direction = Direction(fields[directionColumn-1])
+		}
+
+		punches = append(punches, Punch{Line: line, Code: code, At: at, Direction: direction})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read log: %w", err)
+	}
+
+	return punches, lineErrors, nil
+}
+
+// ------- ZKTeco states 0 check-in, 1 check-out, 4/5 overtime in/out, the rest (breaks) has no direction
+func Direction(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "0", "4", "i", "in", "checkin", "check_in":
+		return "in"
+	case "1", "5", "o", "out", "checkout", "check_out":
+		return "out"
+	}
+	return ""
+}
+
+func splitLine(text string) ([]string, error) {
+	if strings.Contains(text, "\t") {
+		return strings.Split(text, "\t"), nil
+	}
+	if strings.Contains(text, ",") {
+		return csv.NewReader(strings.NewReader(text)).Read()
+	}
+
+	// space separated, a "2006-01-02 15:04:05" timestamp is two fields, a "2006-01-02T15:04:05" one isn't
+	fields := strings.Fields(text)
+	if len(fields) >= 3 && strings.Contains(fields[2], ":") {
+		fields = append([]string{fields[0], fields[1] + " " + fields[2]}, fields[3:]...)
+	}
+	return fields, nil
+}
+
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if at, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
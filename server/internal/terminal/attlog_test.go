@@ -0,0 +1,142 @@
+package terminal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAttlog(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04:05", value, kolkata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name            string
+		log             string
+		directionColumn int
+		want            []Punch
+		wantErrorLines  []int
+	}{
+		{
+			name:            "zkteco tab separated with a BOM",
+			log:             "\ufeff    42\t2026-10-19 08:59:12\t1\t0\t1\t0\n    42\t2026-10-19 18:01:00\t1\t1\t1\t0\n",
+			directionColumn: 4,
+			want: []Punch{
+				{Line: 1, Code: "42", At: at("2026-10-19 08:59:12"), Direction: "in"},
+				{Line: 2, Code: "42", At: at("2026-10-19 18:01:00"), Direction: "out"},
+			},
+		},
+		{
+			name:            "comma separated with quotes",
+			log:             "\"E-7\",\"2026/10/19 09:15:00\",I\nE-7,2026-10-19T17:45:30,O\n",
+			directionColumn: 3,
+			want: []Punch{
+				{Line: 1, Code: "E-7", At: at("2026-10-19 09:15:00"), Direction: "in"},
+				{Line: 2, Code: "E-7", At: at("2026-10-19 17:45:30"), Direction: "out"},
+			},
+		},
+		{
+			name:            "space separated, the timestamp spans two fields",
+			log:             "42  2026-10-19 08:59:12  1  0\n42 2026-10-19 18:01 1 1\n",
+			directionColumn: 4,
+			want: []Punch{
+				{Line: 1, Code: "42", At: at("2026-10-19 08:59:12"), Direction: "in"},
+				{Line: 2, Code: "42", At: at("2026-10-19 18:01:00"), Direction: "out"},
+			},
+		},
+		{
+			name:            "space separated with a single token timestamp",
+			log:             "42 2026-10-19T08:59:12 0\n",
+			directionColumn: 3,
+			want: []Punch{
+				{Line: 1, Code: "42", At: at("2026-10-19 08:59:12"), Direction: "in"},
+			},
+		},
+		{
+			name:            "no direction column",
+			log:             "42\t2026-10-19 08:59:12\t1\t1\n",
+			directionColumn: 0,
+			want: []Punch{
+				{Line: 1, Code: "42", At: at("2026-10-19 08:59:12")},
+			},
+		},
+		{
+			name:            "direction column past the end of the line",
+			log:             "42\t2026-10-19 08:59:12\n",
+			directionColumn: 4,
+			want: []Punch{
+				{Line: 1, Code: "42", At: at("2026-10-19 08:59:12")},
+			},
+		},
+		{
+			name:            "unknown status has no direction",
+			log:             "42\t2026-10-19 13:00:00\t1\t2\n",
+			directionColumn: 4,
+			want: []Punch{
+				{Line: 1, Code: "42", At: at("2026-10-19 13:00:00")},
+			},
+		},
+		{
+			name:            "blank lines and comments keep the line numbers",
+			log:             "# exported from gate-1\n\n42\t2026-10-19 08:59:12\t1\t0\n",
+			directionColumn: 4,
+			want: []Punch{
+				{Line: 3, Code: "42", At: at("2026-10-19 08:59:12"), Direction: "in"},
+			},
+		},
+		{
+			name:            "invalid lines are reported, the rest is kept",
+			log:             "42\n42\t19.10.2026 08:59\t1\t0\n\t2026-10-19 08:59:12\t1\t0\n43\t2026-10-19 09:01:00\t1\t0\n",
+			directionColumn: 4,
+			want: []Punch{
+				{Line: 4, Code: "43", At: at("2026-10-19 09:01:00"), Direction: "in"},
+			},
+			wantErrorLines: []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			punches, lineErrors, err := ParseAttlog(strings.NewReader(tt.log), kolkata, tt.directionColumn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(punches, tt.want) {
+				t.Errorf("punches = %+v, want %+v", punches, tt.want)
+			}
+
+			var errorLines []int
+			for _, lineError := range lineErrors {
+				errorLines = append(errorLines, lineError.Line)
+			}
+			if !reflect.DeepEqual(errorLines, tt.wantErrorLines) {
+				t.Errorf("invalid lines = %v, want %v", errorLines, tt.wantErrorLines)
+			}
+		})
+	}
+}
+
+func TestDirection(t *testing.T) {
+	tests := map[string]string{
+		"0": "in", "4": "in", "I": "in", " in ": "in", "CheckIn": "in", "check_in": "in",
+		"1": "out", "5": "out", "o": "out", "OUT": "out", "checkout": "out", "check_out": "out",
+		"2": "", "3": "", "": "", "break": "",
+	}
+
+	for value, want := range tests {
+		if got := Direction(value); got != want {
+			t.Errorf("Direction(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
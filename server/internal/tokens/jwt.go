@@ -0,0 +1,48 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Sign signs with the active key and puts its kid in the header
+func Sign(claims jwt.MapClaims) (string, error) {
+	if signing == nil {
+		return "", errors.New("JWT keys are not loaded")
+	}
+
+	token := jwt.NewWithClaims(signing.method, claims)
+	token.Header["kid"] = signing.kid
+	return token.SignedString(signing.private)
+}
+
+// ------- the kid picks the key and the key decides the algorithm, so a token can't pick its own
+// (no "none", no HS256 signed with the public key). exp is required
+func Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("kid %s does not sign with %s", kid, token.Method.Alg())
+		}
+		return k.public, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
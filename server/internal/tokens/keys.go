@@ -0,0 +1,193 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ------- one key from JWT_KEYS_DIR. private keys can sign and verify, *.pub.pem files only verify,
+// which is how a retired key stays valid until the tokens it signed have expired
+type key struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+var (
+	signing *key
+	keys    = map[string]*key{}
+)
+
+// ------- loads every *.pem in JWT_KEYS_DIR (default "keys"), the file name is the kid.
+// JWT_ACTIVE_KID picks the signing key, it can be left out when there is a single private key.
+// exits when nothing usable is found, there is no fallback to a shared secret
+func InitKeys() {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "keys"
+	}
+
+	if err := loadKeys(dir, os.Getenv("JWT_ACTIVE_KID")); err != nil {
+		log.Fatalf("JWT keys: %v (create one with: go run ./cmd/keygen -dir %s)", err, dir)
+	}
+
+	kids := make([]string, 0, len(keys))
+	for kid := range keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	log.Printf("JWT signing with %s (%s), verifying %s", signing.kid, signing.method.Alg(), strings.Join(kids, ", "))
+}
+
+func loadKeys(dir string, activeKID string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	var private []*key
+	for _, file := range files {
+		k, err := readKey(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if _, dup := keys[k.kid]; dup {
+			return fmt.Errorf("kid %s is in more than one file", k.kid)
+		}
+		keys[k.kid] = k
+		if k.private != nil {
+			private = append(private, k)
+		}
+	}
+
+	switch {
+	case len(private) == 0:
+		return errors.New("no private key in " + dir)
+	case activeKID != "":
+		k, ok := keys[activeKID]
+		if !ok || k.private == nil {
+			return fmt.Errorf("JWT_ACTIVE_KID %s has no private key in %s", activeKID, dir)
+		}
+		signing = k
+	case len(private) == 1:
+		signing = private[0]
+	default:
+		return errors.New("several private keys, set JWT_ACTIVE_KID")
+	}
+
+	return nil
+}
+
+func readKey(file string) (*key, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+
+	kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".pem"), ".pub")
+	k := &key{kid: kid}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		k.private = signer
+		k.public = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		k.private = parsed
+		k.public = parsed.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		k.public = parsed
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s", block.Type)
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		k.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return k, nil
+}
+
+// ------- public half of every loaded key in RFC 7517 form, for /.well-known/jwks.json
+func JWKS() map[string]any {
+	kids := make([]string, 0, len(keys))
+	for kid := range keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := make([]map[string]any, 0, len(kids))
+	for _, kid := range kids {
+		k := keys[kid]
+		jwk := map[string]any{"kid": k.kid, "alg": k.method.Alg(), "use": "sig"}
+
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		set = append(set, jwk)
+	}
+
+	return map[string]any{"keys": set}
+}
+
+// KeyID derives a kid from the public key when the key file doesn't get a name of its own
+func KeyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
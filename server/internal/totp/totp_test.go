@@ -0,0 +1,129 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ------- RFC 6238 appendix B, the SHA1 key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateRFCVectors(t *testing.T) {
+	key, err := encoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		unix int64
+		want string // ---------------- last 6 digits of the RFC's 8 digit codes
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := generate(key, tt.unix/Period); got != tt.want {
+			t.Errorf("generate(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / Period
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, "050471", 0, step, true},
+		{"surrounding spaces", rfcSecret, " 050471 ", 0, step, true},
+		{"lower case secret", strings.ToLower(rfcSecret), "050471", 0, step, true},
+		{"previous step", rfcSecret, "081804", 0, step - 1, true},
+		{"wrong code", rfcSecret, "123456", 0, 0, false},
+		{"too short", rfcSecret, "50471", 0, 0, false},
+		{"too long", rfcSecret, "0504710", 0, 0, false},
+		{"invalid secret", "not base32!", "050471", 0, 0, false},
+		{"already used step", rfcSecret, "050471", step, 0, false},
+		{"older step than the last used", rfcSecret, "081804", step - 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := Validate(tt.secret, tt.code, now, tt.lastStep)
+			if gotStep != tt.wantStep || gotOK != tt.wantOK {
+				t.Errorf("Validate() = (%d, %v), want (%d, %v)", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	key, _ := encoding.DecodeString(rfcSecret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / Period
+
+	tests := []struct {
+		offset int64
+		wantOK bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+
+	for _, tt := range tests {
+		code := generate(key, current+tt.offset)
+		step, ok := Validate(rfcSecret, code, now, 0)
+		if ok != tt.wantOK || (ok && step != current+tt.offset) {
+			t.Errorf("code of step %+d: Validate() = (%d, %v), want ok %v", tt.offset, step, ok, tt.wantOK)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("NewSecret() = %q, want 20 bytes of unpadded base32", secret)
+	}
+
+	if other, _ := NewSecret(); other == secret {
+		t.Error("NewSecret() returned the same secret twice")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("Acme Attendance", "jane@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Acme Attendance:jane@example.com" {
+		t.Errorf("ProvisioningURI() = %s, want otpauth://totp/<issuer>:<account>", uri)
+	}
+
+	want := map[string]string{"secret": rfcSecret, "issuer": "Acme Attendance", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for key, value := range want {
+		if got := uri.Query().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
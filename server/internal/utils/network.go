@@ -0,0 +1,17 @@
+package utils
+
+import "net"
+
+// IPInNetworks reports whether ip is inside one of the CIDRs, invalid entries never match
+func IPInNetworks(ip string, cidrs []string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,10 +7,16 @@ import (
 )
 
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message,omitempty"`
+	Data    interface{}  `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 func SuccessResponse(c *gin.Context, data interface{}) {
@@ -26,3 +32,11 @@ func ErrorResponse(c *gin.Context, statusCode int, message string) {
 		Error:   message,
 	})
 }
+
+func ValidationErrorResponse(c *gin.Context, message string, errs []FieldError) {
+	c.JSON(http.StatusUnprocessableEntity, Response{
+		Success: false,
+		Error:   message,
+		Errors:  errs,
+	})
+}